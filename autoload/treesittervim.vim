@@ -13,6 +13,17 @@ function! s:start_server() abort
     return 0
   endif
 
+  if exists('g:treesitter_server_address')
+    let s:ch = ch_open(g:treesitter_server_address, {'mode': 'nl', 'callback': 'treesittervim#handle', 'close_cb': function('s:close_cb')})
+    if ch_status(s:ch) !=# 'open'
+      unlet s:ch
+      let s:disabled = 1
+      echohl WarningMsg | echomsg 'cannot connect to treesitter-server at ' . g:treesitter_server_address | echohl None
+      return 0
+    endif
+    return 1
+  endif
+
   if !executable(s:server)
     let l:dir = s:dir . '/cmd/treesitter-server'
     if has('win32')
@@ -34,18 +45,41 @@ function! s:start_server() abort
     endif
     let s:disabled = 0
   endif
-  let s:job = job_start(s:server, {'noblock': 1})
+  let l:cmd = [s:server, '-format', 'compact']
+  if exists('g:treesitter_query_path')
+    let l:cmd += ['-queries', g:treesitter_query_path]
+  endif
+  let s:job = job_start(l:cmd, {'noblock': 1, 'callback': 'treesittervim#handle', 'exit_cb': function('s:exit_cb')})
   let s:ch = job_getchannel(s:job)
   return 1
 endfunction
 
+" Restart the server when it dies, but give up when it keeps dying.
+let s:restarts = []
+function! s:exit_cb(job, status) abort
+  unlet! s:ch s:job
+  let l:now = localtime()
+  call filter(s:restarts, 'l:now - v:val < 60')
+  if len(s:restarts) >= 3
+    let s:disabled = 1
+    echohl WarningMsg | echomsg 'treesitter-server exited repeatedly (status ' . a:status . '), disabled' | echohl None
+    return
+  endif
+  call add(s:restarts, l:now)
+  call timer_start(100, {-> treesittervim#fire(1)})
+endfunction
+
+function! s:close_cb(ch) abort
+  unlet! s:ch
+endfunction
+
 function! s:prop_type_add(name, attr) abort
   if empty(prop_type_get(a:name))
     call prop_type_add(a:name, a:attr)
   endif
 endfunction
 
-let s:syntax = ['TSAnnotation', 'TSAttribute', 'TSBoolean', 'TSCharacter', 'TSComment', 'TSConditional', 'TSConstBuiltin', 'TSConstMacro', 'TSConstant', 'TSConstructor', 'TSDanger', 'TSEmphasis', 'TSEnvironment', 'TSEnvironmentName', 'TSException', 'TSField', 'TSFloat', 'TSFuncBuiltin', 'TSFuncMacro', 'TSFunction', 'TSInclude', 'TSKeyword', 'TSKeywordFunction', 'TSKeywordOperator', 'TSKeywordReturn', 'TSLabel', 'TSLiteral', 'TSMath', 'TSMethod', 'TSNamespace', 'TSNone', 'TSNote', 'TSNumber', 'TSOperator', 'TSParameter', 'TSParameterReference', 'TSProperty', 'TSPunctBracket', 'TSPunctDelimiter', 'TSPunctSpecial', 'TSRepeat', 'TSStrike', 'TSString', 'TSStringEscape', 'TSStringRegex', 'TSStringSpecial', 'TSStrong', 'TSSymbol', 'TSTag', 'TSTagAttribute', 'TSTagDelimiter', 'TSText', 'TSTextReference', 'TSTitle', 'TSType', 'TSTypeBuiltin', 'TSURI', 'TSUnderline', 'TSVariableBuiltin', 'TSWarning']
+let s:syntax = ['TSAnnotation', 'TSAttribute', 'TSBoolean', 'TSCharacter', 'TSComment', 'TSConditional', 'TSConstBuiltin', 'TSConstMacro', 'TSConstant', 'TSConstructor', 'TSDanger', 'TSEmphasis', 'TSEnvironment', 'TSEnvironmentName', 'TSError', 'TSException', 'TSField', 'TSFloat', 'TSFuncBuiltin', 'TSFuncMacro', 'TSFunction', 'TSFunctionBuiltin', 'TSFunctionMacro', 'TSInclude', 'TSKeyword', 'TSKeywordFunction', 'TSKeywordOperator', 'TSKeywordReturn', 'TSLabel', 'TSLiteral', 'TSMath', 'TSMethod', 'TSNamespace', 'TSNone', 'TSNote', 'TSNumber', 'TSOperator', 'TSParameter', 'TSParameterReference', 'TSProperty', 'TSPunctBracket', 'TSPunctDelimiter', 'TSPunctSpecial', 'TSRepeat', 'TSStrike', 'TSString', 'TSStringEscape', 'TSStringRegex', 'TSStringSpecial', 'TSStrong', 'TSSymbol', 'TSTag', 'TSTagAttribute', 'TSTagDelimiter', 'TSText', 'TSTextReference', 'TSTitle', 'TSType', 'TSTypeBuiltin', 'TSURI', 'TSUnderline', 'TSVariable', 'TSVariableBuiltin', 'TSWarning']
 for s:s in s:syntax
   call s:prop_type_add(s:s, {'highlight': s:s})
 endfor
@@ -57,9 +91,15 @@ function! treesittervim#handle(ch, msg) abort
     if l:v[0] == 'version'
       call s:handle_version(l:v[1])
     elseif l:v[0] == 'syntax'
-      call s:handle_syntax(l:v[1])
+      call s:handle_syntax(l:v[1], get(l:v, 2, bufnr('%')))
     elseif l:v[0] == 'textobj'
       call s:handle_textobj(l:v[1])
+    elseif l:v[0] == 'rewrite'
+      call s:handle_rewrite(l:v[1], get(l:v, 2, bufnr('%')))
+    elseif l:v[0] == 'reload_queries'
+      call treesittervim#fire(1)
+    elseif l:v[0] == 'error'
+      echohl ErrorMsg | echomsg 'treesitter-server: ' . split(l:v[1], "\n")[0] | echohl None
     endif
   catch
   endtry
@@ -67,24 +107,47 @@ endfunction
 
 function! treesittervim#redraw(range) abort
   call s:clear()
+  let l:types = get(b:, 'treesitter_types', [])
+  let l:row = a:range[0]
   for l:line in b:treesitter_proplines[a:range[0] : a:range[1]]
-    for l:prop in l:line
+    let l:row += 1
+    if empty(l:types)
+      for l:prop in l:line
+        try
+          call prop_add(l:prop.row, l:prop.col, l:prop.attr)
+        catch
+        endtry
+      endfor
+      continue
+    endif
+    " compact format: column, length and index into types for each prop
+    for l:i in range(0, len(l:line) - 1, 3)
       try
-        call prop_add(l:prop.row, l:prop.col, l:prop.attr)
+        call prop_add(l:row, l:line[l:i], {'length': l:line[l:i+1], 'type': l:types[l:line[l:i+2]]})
       catch
       endtry
     endfor
   endfor
 endfunction
 
-function! s:handle_syntax(value) abort
-  if &l:syntax != ''
-    let b:treesitter_syntax = &l:syntax
-    let &l:syntax = ''
+function! s:handle_syntax(value, bufnr) abort
+  let l:bufnr = str2nr(a:bufnr)
+  let l:syntax = getbufvar(l:bufnr, '&syntax')
+  if l:syntax != ''
+    call setbufvar(l:bufnr, 'treesitter_syntax', l:syntax)
+    call setbufvar(l:bufnr, '&syntax', '')
+  endif
+  if type(a:value) == v:t_dict
+    call setbufvar(l:bufnr, 'treesitter_types', a:value.types)
+    call setbufvar(l:bufnr, 'treesitter_proplines', a:value.lines)
+  else
+    call setbufvar(l:bufnr, 'treesitter_types', [])
+    call setbufvar(l:bufnr, 'treesitter_proplines', a:value)
+  endif
+  call setbufvar(l:bufnr, 'treesitter_range', [-1, -1])
+  if l:bufnr == bufnr('%')
+    call treesittervim#fire(0)
   endif
-  let b:treesitter_proplines = a:value
-  let b:treesitter_range = [-1, -1]
-  call treesittervim#fire(0)
 endfunc
 
 function! s:clear() abort
@@ -96,7 +159,7 @@ endfunction
 function! treesittervim#syntax() abort
   try
     let l:lines = join(getline(1, '$'), "\n")
-    call ch_sendraw(s:ch, json_encode(['syntax', &filetype, l:lines]) . "\n", {'callback': 'treesittervim#handle'})
+    call ch_sendraw(s:ch, json_encode(['syntax', &filetype, l:lines, '' . bufnr('%'), expand('%:p')]) . "\n")
   catch
     echomsg v:exception
   endtry
@@ -112,7 +175,15 @@ endfunc
 function! treesittervim#version() abort
   try
     let l:lines = join(getline(1, '$'), "\n")
-    call ch_sendraw(s:ch, json_encode(['version']) . "\n", {'callback': 'treesittervim#handle'})
+    call ch_sendraw(s:ch, json_encode(['version']) . "\n")
+  catch
+    echomsg v:exception
+  endtry
+endfunction
+
+function! treesittervim#reload_queries() abort
+  try
+    call ch_sendraw(s:ch, json_encode(['reload_queries']) . "\n")
   catch
     echomsg v:exception
   endtry
@@ -130,7 +201,36 @@ endfunc
 function! treesittervim#textobj() abort
   try
     let l:lines = join(getline(1, '$'), "\n")
-    call ch_sendraw(s:ch, json_encode(['textobj', &filetype, l:lines, '' . (col('.')-1), '' . (line('.')-1)]) . "\n", {'callback': 'treesittervim#handle'})
+    call ch_sendraw(s:ch, json_encode(['textobj', &filetype, l:lines, '' . (col('.')-1), '' . (line('.')-1), '' . bufnr('%'), expand('%:p')]) . "\n")
+  catch
+    echomsg v:exception
+  endtry
+endfunction
+
+function! s:handle_rewrite(edits, bufnr) abort
+  if a:bufnr != bufnr('%')
+    return
+  endif
+  " The edits are in order, apply them from the last so that the positions
+  " of the others stay valid.
+  for l:e in reverse(copy(a:edits))
+    let l:lnum = l:e['start'].row + 1
+    let l:count = l:e['end'].row - l:e['start'].row + 1
+    let l:new = split(strpart(getline(l:lnum), 0, l:e['start'].column) . l:e['text'] . strpart(getline(l:lnum + l:count - 1), l:e['end'].column), "\n", 1)
+    if len(l:new) < l:count
+      silent execute (l:lnum + len(l:new)) . ',' . (l:lnum + l:count - 1) . 'delete _'
+    elseif len(l:new) > l:count
+      call append(l:lnum + l:count - 1, l:new[l:count :])
+    endif
+    call setline(l:lnum, l:new[: min([len(l:new), l:count]) - 1])
+  endfor
+  echomsg printf('treesitter-server: %d edits', len(a:edits))
+endfunction
+
+function! treesittervim#rewrite(query, template) abort
+  try
+    let l:lines = join(getline(1, '$'), "\n")
+    call ch_sendraw(s:ch, json_encode(['rewrite', &filetype, l:lines, a:query, a:template, '' . bufnr('%'), expand('%:p')]) . "\n")
   catch
     echomsg v:exception
   endtry
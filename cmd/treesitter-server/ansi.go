@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readSource reads file, or stdin for "-", and returns its text and
+// language, which is lname or else detected from the name and the text.
+func readSource(file, lname string) (string, string, error) {
+	var b []byte
+	var err error
+	if file == "-" {
+		b, err = io.ReadAll(os.Stdin)
+		file = ""
+	} else {
+		b, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	code := string(b)
+	if lname != "" {
+		if l := lookupLanguage(lname); l != "" {
+			return code, l, nil
+		}
+		return "", "", fmt.Errorf("%w: %s", errUnknownLanguage, lname)
+	}
+	if l := detectLanguage("", file, code); l != "" {
+		return code, l, nil
+	}
+	if file == "" {
+		file = "stdin"
+	}
+	return "", "", fmt.Errorf("cannot detect the language of %s, use -lang", file)
+}
+
+// spans calls f with the pieces of line in order, each with the highlight
+// group of its prop or "" for text between props.
+func spans(line string, props []Prop, f func(text, group string)) {
+	col := 0
+	for _, p := range props {
+		start := p.Col - 1
+		end := start + p.Attr.Length
+		if p.Attr.Length == EOL || end > len(line) {
+			end = len(line)
+		}
+		if start < col || start >= end {
+			continue
+		}
+		if start > col {
+			f(line[col:start], "")
+		}
+		f(line[start:end], p.Attr.Type)
+		col = end
+	}
+	if col < len(line) {
+		f(line[col:], "")
+	}
+}
+
+// renderANSI writes code with the props of each line colored with
+// ANSI escape sequences.
+func renderANSI(w io.Writer, code string, lines [][]Prop, t theme) {
+	for i, line := range strings.Split(code, "\n") {
+		if i > 0 {
+			io.WriteString(w, "\n")
+		}
+		var props []Prop
+		if i < len(lines) {
+			props = lines[i]
+		}
+		spans(line, props, func(text, group string) {
+			if sgr := t[group].sgr(); sgr != "" {
+				fmt.Fprintf(w, "\x1b[%sm%s\x1b[0m", sgr, text)
+			} else {
+				io.WriteString(w, text)
+			}
+		})
+	}
+}
+
+func highlightCommand(args []string) int {
+	fs := flag.NewFlagSet("highlight", flag.ExitOnError)
+	var lname, themeFile string
+	fs.StringVar(&lname, "lang", "", "language of the files (default: detected)")
+	fs.StringVar(&themeFile, "theme", "", "file with the colors of the highlight groups")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s highlight [-lang name] [-theme file] [file...]\n", name)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	t, err := loadTheme(themeFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "highlight: %v\n", err)
+		return 2
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	ps := parsers{}
+	status := 0
+	for _, file := range files {
+		code, l, err := readSource(file, lname)
+		if err == nil {
+			var lines [][]Prop
+			lines, err = doSyntax(context.Background(), ps, l, code)
+			if err == nil {
+				renderANSI(w, code, lines, t)
+				continue
+			}
+		}
+		w.Flush()
+		fmt.Fprintf(os.Stderr, "highlight: %v\n", err)
+		status = 1
+	}
+	return status
+}
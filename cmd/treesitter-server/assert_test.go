@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mattn/vim-treesitter/internal/query"
+)
+
+// assertion is a highlight assertion comment in a test fixture.
+//
+//	if x {
+//	// <- TSConditional
+//	// ^ !TSKeyword
+//
+// "<-" refers to the column where the comment starts, each "^" to its own
+// column, both on the closest line above which is not an assertion.
+type assertion struct {
+	line   int
+	row    int
+	col    int
+	group  string
+	negate bool
+}
+
+var assertionRe = regexp.MustCompile(`^\s*(//|#|--|;|%|/\*|\(\*|<!--)\s*(<-|\^+)\s*(!?)([\w.@]+)`)
+
+func parseAssertions(code string) []assertion {
+	var result []assertion
+	row := 0
+	for i, line := range strings.Split(code, "\n") {
+		m := assertionRe.FindStringSubmatchIndex(line)
+		if m == nil || row == 0 {
+			row = i + 1
+			continue
+		}
+		group := line[m[8]:m[9]]
+		if !strings.HasPrefix(group, "TS") {
+			group = query.HighlightGroup(strings.TrimPrefix(group, "@"))
+		}
+		a := assertion{line: i + 1, row: row, group: group, negate: m[6] != m[7]}
+		if line[m[4]:m[5]] == "<-" {
+			a.col = m[2] + 1
+			result = append(result, a)
+			continue
+		}
+		for col := m[4]; col < m[5]; col++ {
+			a.col = col + 1
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// highlightAt returns the highlight group at the 1-based row and byte column.
+func highlightAt(lines [][]Prop, row, col int) string {
+	if row < 1 || row > len(lines) {
+		return ""
+	}
+	for _, p := range lines[row-1] {
+		if col >= p.Col && (p.Attr.Length == EOL || col < p.Col+p.Attr.Length) {
+			return p.Attr.Type
+		}
+	}
+	return ""
+}
+
+func TestSyntaxAssertions(t *testing.T) {
+	ps := parsers{}
+	for _, lname := range sortedLanguages() {
+		for _, file := range samples(t, lname) {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertions := parseAssertions(string(b))
+			if len(assertions) == 0 {
+				continue
+			}
+			t.Run(file, func(t *testing.T) {
+				lines, err := doSyntax(context.Background(), ps, lname, string(b))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, a := range assertions {
+					got := highlightAt(lines, a.row, a.col)
+					if a.negate && got == a.group {
+						t.Errorf("%s:%d: %d:%d is highlighted as %s", file, a.line, a.row, a.col, got)
+					} else if !a.negate && got != a.group {
+						t.Errorf("%s:%d: %d:%d is highlighted as %q, want %s", file, a.line, a.row, a.col, got, a.group)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestParseAssertions(t *testing.T) {
+	code := "if x == 1 {\n// <- TSConditional\n//   ^^ !TSString\n  foo()\n  # ^ function.call\n"
+	want := []assertion{
+		{line: 2, row: 1, col: 1, group: "TSConditional"},
+		{line: 3, row: 1, col: 6, group: "TSString", negate: true},
+		{line: 3, row: 1, col: 7, group: "TSString", negate: true},
+		{line: 5, row: 4, col: 5, group: "TSFunctionCall"},
+	}
+	got := parseAssertions(code)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("assertion %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
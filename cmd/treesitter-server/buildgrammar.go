@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// grammarName returns the name of the grammar in dir from
+// src/grammar.json, or from the name of the directory.
+func grammarName(dir string) string {
+	if b, err := os.ReadFile(filepath.Join(dir, "src", "grammar.json")); err == nil {
+		var g struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(b, &g) == nil && g.Name != "" {
+			return g.Name
+		}
+	}
+	base := filepath.Base(dir)
+	if abs, err := filepath.Abs(dir); err == nil {
+		base = filepath.Base(abs)
+	}
+	return strings.TrimPrefix(base, "tree-sitter-")
+}
+
+func compiler(env, def string) string {
+	if cc := os.Getenv(env); cc != "" {
+		return cc
+	}
+	return def
+}
+
+func run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", strings.Join(cmd.Args, " "), err)
+	}
+	return nil
+}
+
+// buildGrammar compiles the parser and scanner in dir/src into
+// out/<lang>.so and copies the queries of dir/queries to
+// out/queries/<lang>. Nothing is installed unless the library loads.
+func buildGrammar(dir, lname, out string) (string, error) {
+	src := filepath.Join(dir, "src")
+	if _, err := os.Stat(filepath.Join(src, "parser.c")); err != nil {
+		return "", fmt.Errorf("%s is not a grammar: %w", dir, err)
+	}
+	tmp, err := os.MkdirTemp("", "build-grammar")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	cc := compiler("CC", "cc")
+	cxx := compiler("CXX", "c++")
+	link := cc
+	var objects []string
+	for _, file := range []string{"parser.c", "scanner.c", "scanner.cc", "scanner.cpp"} {
+		path := filepath.Join(src, file)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		c := cc
+		if filepath.Ext(file) != ".c" {
+			c, link = cxx, cxx
+		}
+		obj := filepath.Join(tmp, file+".o")
+		if err := run(c, "-fPIC", "-O2", "-I", src, "-c", "-o", obj, path); err != nil {
+			return "", err
+		}
+		objects = append(objects, obj)
+	}
+
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return "", err
+	}
+	lib := filepath.Join(out, lname+sharedLibraryExt())
+	shared := "-shared"
+	if runtime.GOOS == "darwin" {
+		shared = "-dynamiclib"
+	}
+	// Build next to the target and rename, so that running servers keep
+	// the library they loaded. The name is unique because a library is
+	// only loaded once per path.
+	f, err := os.CreateTemp(out, "."+lname+"-*"+sharedLibraryExt())
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	tmpLib := f.Name()
+	args := append([]string{shared, "-o", tmpLib}, objects...)
+	if err := run(link, args...); err != nil {
+		os.Remove(tmpLib)
+		return "", err
+	}
+	// A library which can't be used must not replace the installed one.
+	if _, err := openLanguage(tmpLib, languageSymbol(lname)); err != nil {
+		os.Remove(tmpLib)
+		return "", err
+	}
+	if err := os.Rename(tmpLib, lib); err != nil {
+		os.Remove(tmpLib)
+		return "", err
+	}
+
+	queries, _ := filepath.Glob(filepath.Join(dir, "queries", "*.scm"))
+	if len(queries) > 0 {
+		qdir := filepath.Join(out, "queries", lname)
+		if err := os.MkdirAll(qdir, 0755); err != nil {
+			return "", err
+		}
+		for _, q := range queries {
+			b, err := os.ReadFile(q)
+			if err != nil {
+				return "", err
+			}
+			if err := os.WriteFile(filepath.Join(qdir, filepath.Base(q)), b, 0644); err != nil {
+				return "", err
+			}
+		}
+	}
+	return lib, nil
+}
+
+func buildGrammarCommand(args []string) int {
+	fs := flag.NewFlagSet("build-grammar", flag.ExitOnError)
+	var lname, out string
+	fs.StringVar(&lname, "name", "", "name of the language (default: from src/grammar.json)")
+	fs.StringVar(&out, "o", "", "directory to install to (default: the first directory of the grammar path)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s build-grammar [-name lang] [-o dir] grammar-dir\n", name)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if runtime.GOOS == "windows" {
+		fmt.Fprintln(os.Stderr, "build-grammar: loading grammars is not supported on Windows")
+		return 1
+	}
+	dir := fs.Arg(0)
+	if lname == "" {
+		lname = grammarName(dir)
+	}
+	if out == "" {
+		if len(grammarPath) == 0 {
+			fmt.Fprintln(os.Stderr, "build-grammar: no grammar path, use -o")
+			return 2
+		}
+		out = grammarPath[0]
+	}
+	lib, err := buildGrammar(dir, lname, out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "build-grammar: %v\n", err)
+		return 1
+	}
+	fmt.Println(lib)
+	return 0
+}
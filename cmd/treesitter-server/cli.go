@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/vim-treesitter/internal/query"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// parseSource reads and parses file, or stdin for "-", as lname or the
+// detected language.
+func parseSource(ps parsers, file, lname string) ([]byte, string, *sitter.Tree, error) {
+	code, l, err := readSource(file, lname)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	parser, _, err := ps.get(l)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	tree, err := parse(context.Background(), parser, []byte(code))
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return []byte(code), l, tree, nil
+}
+
+// writeTree writes the named nodes under n as an indented S-expression with
+// field names and the ranges of the nodes, as tree-sitter parse does.
+func writeTree(w io.Writer, n *sitter.Node, field string, depth int) {
+	io.WriteString(w, strings.Repeat("  ", depth))
+	if field != "" {
+		io.WriteString(w, field+": ")
+	}
+	typ := n.Type()
+	if n.IsMissing() {
+		typ = "MISSING " + typ
+	}
+	fmt.Fprintf(w, "(%s [%d, %d] - [%d, %d]", typ,
+		n.StartPoint().Row, n.StartPoint().Column, n.EndPoint().Row, n.EndPoint().Column)
+	for i := 0; i < int(n.ChildCount()); i++ {
+		c := n.Child(i)
+		if !c.IsNamed() && !c.IsMissing() {
+			continue
+		}
+		io.WriteString(w, "\n")
+		writeTree(w, c, n.FieldNameForChild(i), depth+1)
+	}
+	io.WriteString(w, ")")
+}
+
+// lineRange parses a -range of 1-based lines "first,last" or "line".
+func lineRange(s string) (first, last int, err error) {
+	a, b, ok := strings.Cut(s, ",")
+	if !ok {
+		b = a
+	}
+	first, err1 := strconv.Atoi(a)
+	last, err2 := strconv.Atoi(b)
+	if err1 != nil || err2 != nil || first < 1 || last < first {
+		return 0, 0, fmt.Errorf("invalid range %q", s)
+	}
+	return first, last, nil
+}
+
+// rangeNode returns the smallest named node spanning the lines first to
+// last of code.
+func rangeNode(root *sitter.Node, code []byte, first, last int) *sitter.Node {
+	lines := strings.Split(string(code), "\n")
+	if last > len(lines) {
+		last = len(lines)
+	}
+	if first > last {
+		return nil
+	}
+	start := sitter.Point{Row: uint32(first - 1)}
+	end := sitter.Point{Row: uint32(last - 1), Column: uint32(len(lines[last-1]))}
+	return root.NamedDescendantForPointRange(start, end)
+}
+
+func parseCommand(args []string) int {
+	fs := flag.NewFlagSet("parse", flag.ExitOnError)
+	var lname, rng string
+	fs.StringVar(&lname, "lang", "", "language of the files (default: detected)")
+	fs.StringVar(&rng, "range", "", "print the node spanning the lines `first,last` only")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s parse [-lang name] [-range first,last] [file...]\n", name)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	first, last := 0, 0
+	if rng != "" {
+		var err error
+		if first, last, err = lineRange(rng); err != nil {
+			fmt.Fprintf(os.Stderr, "parse: %v\n", err)
+			return 2
+		}
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	ps := parsers{}
+	status := 0
+	for _, file := range files {
+		code, _, tree, err := parseSource(ps, file, lname)
+		if err != nil {
+			w.Flush()
+			fmt.Fprintf(os.Stderr, "parse: %v\n", err)
+			status = 1
+			continue
+		}
+		n := tree.RootNode()
+		if first > 0 {
+			if n = rangeNode(n, code, first, last); n == nil {
+				w.Flush()
+				fmt.Fprintf(os.Stderr, "parse: %s: no lines %s\n", file, rng)
+				status = 1
+				continue
+			}
+		}
+		writeTree(w, n, "", 0)
+		io.WriteString(w, "\n")
+	}
+	return status
+}
+
+// compileQuery compiles a query given on the command line. Unlike query
+// files, a broken pattern is an error.
+func compileQuery(src []byte, lang *sitter.Language) (*highlightQuery, error) {
+	q, err := query.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	sq, err := sitter.NewQuery(src, lang)
+	if err != nil {
+		var qe *sitter.QueryError
+		if errors.As(err, &qe) {
+			return nil, fmt.Errorf("%v: %w", offsetPos(src, int(qe.Offset)), err)
+		}
+		return nil, err
+	}
+	return newHighlightQuery(sq, q.Patterns), nil
+}
+
+// offsetPos returns the line and column of a byte offset of src.
+func offsetPos(src []byte, offset int) query.Pos {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	before := src[:offset]
+	line := strings.Count(string(before), "\n") + 1
+	col := offset - strings.LastIndexByte(string(before), '\n')
+	return query.Pos{Offset: offset, Line: line, Column: col}
+}
+
+// queryMatch is a capture printed by the query command.
+type queryMatch struct {
+	File    string `json:"file"`
+	Pattern int    `json:"pattern"`
+	Capture string `json:"capture"`
+	Type    string `json:"type"`
+	Start   Point  `json:"start"`
+	End     Point  `json:"end"`
+	Text    string `json:"text"`
+}
+
+// queryMatches calls f with the matches of hq in root whose predicates
+// hold.
+func queryMatches(hq *highlightQuery, root *sitter.Node, code []byte, f func(m *sitter.QueryMatch)) {
+	qc := sitter.NewQueryCursor()
+	qc.Exec(hq.q, root)
+	for {
+		m, ok := qc.NextMatch()
+		if !ok {
+			return
+		}
+		if hq.accept(m, code) {
+			f(m)
+		}
+	}
+}
+
+// queryCache compiles a query once for each language it is run on.
+type queryCache struct {
+	src     []byte
+	queries map[string]*highlightQuery
+}
+
+func (qs *queryCache) get(ps parsers, lname string) (*highlightQuery, error) {
+	if hq, ok := qs.queries[lname]; ok {
+		return hq, nil
+	}
+	_, lang, err := ps.get(lname)
+	if err != nil {
+		return nil, err
+	}
+	hq, err := compileQuery(qs.src, lang)
+	if err != nil {
+		return nil, fmt.Errorf("query for %s: %w", lname, err)
+	}
+	qs.queries[lname] = hq
+	return hq, nil
+}
+
+func queryCommand(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	var lname, expr string
+	fs.StringVar(&lname, "lang", "", "language of the files (default: detected)")
+	fs.StringVar(&expr, "e", "", "the query, instead of a query file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s query [-lang name] query.scm|-e query [file...]\n", name)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	files := fs.Args()
+	src := []byte(expr)
+	if expr == "" {
+		if len(files) == 0 {
+			fs.Usage()
+			return 2
+		}
+		b, err := os.ReadFile(files[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "query: %v\n", err)
+			return 2
+		}
+		src, files = b, files[1:]
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	enc := json.NewEncoder(w)
+	ps := parsers{}
+	qs := &queryCache{src: src, queries: map[string]*highlightQuery{}}
+	status := 0
+	for _, file := range files {
+		code, l, tree, err := parseSource(ps, file, lname)
+		var hq *highlightQuery
+		if err == nil {
+			hq, err = qs.get(ps, l)
+		}
+		if err != nil {
+			w.Flush()
+			fmt.Fprintf(os.Stderr, "query: %v\n", err)
+			status = 1
+			continue
+		}
+		queryMatches(hq, tree.RootNode(), code, func(m *sitter.QueryMatch) {
+			for _, c := range m.Captures {
+				enc.Encode(queryMatch{
+					File:    file,
+					Pattern: int(m.PatternIndex),
+					Capture: hq.q.CaptureNameForId(c.Index),
+					Type:    c.Node.Type(),
+					Start:   Point{Row: c.Node.StartPoint().Row, Column: c.Node.StartPoint().Column},
+					End:     Point{Row: c.Node.EndPoint().Row, Column: c.Node.EndPoint().Column},
+					Text:    c.Node.Content(code),
+				})
+			}
+		})
+	}
+	return status
+}
+
+// syntaxErrors returns the ERROR and MISSING nodes under n, outermost
+// first.
+func syntaxErrors(n *sitter.Node) []*sitter.Node {
+	switch {
+	case n.IsMissing(), n.Type() == "ERROR":
+		return []*sitter.Node{n}
+	case !n.HasError():
+		return nil
+	}
+	var errs []*sitter.Node
+	for i := 0; i < int(n.ChildCount()); i++ {
+		errs = append(errs, syntaxErrors(n.Child(i))...)
+	}
+	return errs
+}
+
+func syntaxErrorMessage(n *sitter.Node) string {
+	if n.IsMissing() {
+		return "missing " + n.Type()
+	}
+	return "syntax error"
+}
+
+func checkCommand(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	var lname string
+	fs.StringVar(&lname, "lang", "", "language of the files (default: detected)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s check [-lang name] [file...]\n", name)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	ps := parsers{}
+	status := 0
+	for _, file := range files {
+		_, _, tree, err := parseSource(ps, file, lname)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "check: %v\n", err)
+			status = 2
+			continue
+		}
+		for _, n := range syntaxErrors(tree.RootNode()) {
+			p := n.StartPoint()
+			fmt.Printf("%s:%d:%d: %s\n", file, p.Row+1, p.Column+1, syntaxErrorMessage(n))
+			if status == 0 {
+				status = 1
+			}
+		}
+	}
+	return status
+}
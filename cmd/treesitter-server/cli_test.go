@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+func TestLineRange(t *testing.T) {
+	for _, tt := range []struct {
+		in          string
+		first, last int
+		ok          bool
+	}{
+		{"3", 3, 3, true},
+		{"3,5", 3, 5, true},
+		{"0", 0, 0, false},
+		{"5,3", 0, 0, false},
+		{"a,3", 0, 0, false},
+	} {
+		first, last, err := lineRange(tt.in)
+		if (err == nil) != tt.ok || first != tt.first || last != tt.last {
+			t.Errorf("lineRange(%q) = %d, %d, %v", tt.in, first, last, err)
+		}
+	}
+}
+
+func TestOffsetPos(t *testing.T) {
+	src := []byte("(a)\n(b @x)")
+	for offset, want := range map[int]string{0: "1:1", 2: "1:3", 4: "2:1", 7: "2:4", 99: "2:7"} {
+		if got := offsetPos(src, offset).String(); got != want {
+			t.Errorf("offsetPos(%d) = %s; want %s", offset, got, want)
+		}
+	}
+}
+
+func TestParseCommands(t *testing.T) {
+	ps := parsers{}
+	parser, lang, err := ps.get("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := []byte("package main\n\nfunc f() {}\n\nfunc g() {}\n")
+	tree, err := parse(context.Background(), parser, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tree.RootNode()
+
+	var buf bytes.Buffer
+	writeTree(&buf, root, "", 0)
+	for _, want := range []string{
+		"\n  (function_declaration [2, 0] - [2, 11]\n    name: (identifier [2, 5] - [2, 6])\n",
+		"\n    body: (block [4, 9] - [4, 11]))",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("tree doesn't contain %q:\n%s", want, buf.String())
+		}
+	}
+	if n := rangeNode(root, code, 3, 3); n == nil || n.Type() != "function_declaration" {
+		t.Errorf("rangeNode(3, 3) = %v", n)
+	}
+
+	hq, err := compileQuery([]byte(`(function_declaration name: (identifier) @name (#eq? @name "g"))`), lang)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	queryMatches(hq, root, code, func(m *sitter.QueryMatch) {
+		names = append(names, m.Captures[0].Node.Content(code))
+	})
+	if len(names) != 1 || names[0] != "g" {
+		t.Errorf("matches %v; want [g]", names)
+	}
+	if _, err := compileQuery([]byte("(function_declaration)\n(nothing)"), lang); err == nil || !strings.HasPrefix(err.Error(), "2:") {
+		t.Errorf("compileQuery of an unknown node: %v", err)
+	}
+
+	if errs := syntaxErrors(root); len(errs) != 0 {
+		t.Errorf("errors in valid code: %v", errs)
+	}
+	tree, err = parse(context.Background(), parser, []byte("package main\n\nfunc f() {\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := syntaxErrors(tree.RootNode()); len(errs) == 0 {
+		t.Error("no errors in broken code")
+	}
+}
+
+func TestParseCanceled(t *testing.T) {
+	ps := parsers{}
+	parser, _, err := ps.get("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := []byte("package p\n\n" + strings.Repeat("func f() { g(1, 2, \"x\") }\n", 2000))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := parse(ctx, parser, code); err != errCanceled {
+		t.Errorf("parse with a canceled context: %v", err)
+	}
+	// Neither a canceled parse nor one whose context is done right after
+	// it finished may fail the next parse.
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		if _, err := parse(ctx, parser, code[:100]); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		tree, err := parse(context.Background(), parser, code)
+		if err != nil {
+			t.Fatalf("parse after a cancel: %v", err)
+		}
+		if tree.RootNode().HasError() {
+			t.Fatal("parse after a cancel resumed the old parse")
+		}
+	}
+
+	saved := parseTimeout
+	defer func() { parseTimeout = saved }()
+	parseTimeout = time.Microsecond
+	if _, err := parse(context.Background(), parser, code); err == nil || err.Error() != "parse timed out after 1µs" {
+		t.Errorf("parse past the timeout: %v", err)
+	}
+	parseTimeout = 0
+	if _, err := parse(context.Background(), parser, code); err != nil {
+		t.Errorf("parse after a timeout: %v", err)
+	}
+}
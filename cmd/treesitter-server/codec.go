@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// format is the encoding of requests and responses: "json", "compact" or
+// "msgpack".
+var format = "json"
+
+// noID is the id of requests which expect no answer, which is all of them
+// except msgpack-rpc requests.
+const noID = -1
+
+// codec reads requests and writes responses in one of the formats.
+type codec interface {
+	readRequest() (input []string, id int64, err error)
+	writeResponse(id int64, res *Response) error
+}
+
+func newCodec(format string, r io.Reader, w io.Writer) (codec, error) {
+	switch format {
+	case "json":
+		return &jsonCodec{r: bufio.NewReader(r), enc: json.NewEncoder(w)}, nil
+	case "compact":
+		return &jsonCodec{r: bufio.NewReader(r), enc: json.NewEncoder(w), compact: true}, nil
+	case "msgpack":
+		c := &msgpackCodec{r: bufio.NewReader(r), out: w}
+		c.w = bufio.NewWriter(&c.buf)
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// compactProps are the props of a syntax response as flat arrays of
+// column, length and index into types for each line.
+type compactProps struct {
+	Types []string `json:"types"`
+	Lines [][]int  `json:"lines"`
+}
+
+func compact(lines [][]Prop) *compactProps {
+	c := &compactProps{Types: []string{}, Lines: make([][]int, len(lines))}
+	index := map[string]int{}
+	for i, props := range lines {
+		line := make([]int, 0, 3*len(props))
+		for _, p := range props {
+			t, ok := index[p.Attr.Type]
+			if !ok {
+				t = len(c.Types)
+				index[p.Attr.Type] = t
+				c.Types = append(c.Types, p.Attr.Type)
+			}
+			line = append(line, p.Col, p.Attr.Length, t)
+		}
+		c.Lines[i] = line
+	}
+	return c
+}
+
+// compactResponse returns res with the props of a syntax response in the
+// compact form.
+func compactResponse(res *Response) *Response {
+	if len(*res) < 2 || (*res)[0] != "syntax" {
+		return res
+	}
+	lines, ok := (*res)[1].([][]Prop)
+	if !ok {
+		return res
+	}
+	c := append(Response{}, *res...)
+	c[1] = compact(lines)
+	return &c
+}
+
+type jsonCodec struct {
+	r       *bufio.Reader
+	enc     *json.Encoder
+	compact bool
+}
+
+func (c *jsonCodec) readRequest() ([]string, int64, error) {
+	var buf bytes.Buffer
+	if err := readLine(c.r, &buf); err != nil {
+		return nil, noID, err
+	}
+	input, err := decodeRequest(buf.Bytes())
+	return input, noID, err
+}
+
+func (c *jsonCodec) writeResponse(id int64, res *Response) error {
+	if c.compact {
+		res = compactResponse(res)
+	}
+	return c.enc.Encode(res)
+}
+
+// msgpackCodec speaks msgpack-rpc as Neovim does. A request
+// [0, msgid, command, args] is answered with [1, msgid, error, response],
+// and a notification [2, command, args] with a notification
+// [2, command, values]. Syntax responses are always compact.
+type msgpackCodec struct {
+	r   *bufio.Reader
+	out io.Writer
+
+	// A response is encoded into buf through w, and written out once it
+	// is complete, so that one which fails to encode leaves nothing on
+	// the stream.
+	buf bytes.Buffer
+	w   *bufio.Writer
+}
+
+const (
+	msgpackRequest      = 0
+	msgpackResponse     = 1
+	msgpackNotification = 2
+)
+
+func (c *msgpackCodec) readRequest() ([]string, int64, error) {
+	// The stream can't be resynchronized after a broken message, so
+	// decoding errors end the session.
+	v, err := decodeMsgpack(c.r, 0)
+	if err != nil {
+		return nil, noID, err
+	}
+	msg, ok := v.([]interface{})
+	if !ok || len(msg) == 0 {
+		return nil, noID, fmt.Errorf("%w: not a msgpack-rpc message", errInvalidRequest)
+	}
+	id := int64(noID)
+	switch kind, _ := msg[0].(int64); {
+	case kind == msgpackRequest && len(msg) == 4:
+		n, ok := msg[1].(int64)
+		if !ok || n < 0 || n > 1<<32-1 {
+			return nil, noID, fmt.Errorf("%w: invalid msgid", errInvalidRequest)
+		}
+		id = n
+		msg = msg[2:]
+	case kind == msgpackNotification && len(msg) == 3:
+		msg = msg[1:]
+	default:
+		return nil, noID, fmt.Errorf("%w: not a msgpack-rpc request", errInvalidRequest)
+	}
+	method, ok := msg[0].(string)
+	if !ok || method == "" {
+		return nil, id, fmt.Errorf("%w: invalid method", errInvalidRequest)
+	}
+	params, ok := msg[1].([]interface{})
+	if !ok {
+		return nil, id, fmt.Errorf("%w: params must be an array", errInvalidRequest)
+	}
+	input := []string{method}
+	for _, p := range params {
+		switch p := p.(type) {
+		case string:
+			input = append(input, p)
+		case int64:
+			input = append(input, strconv.FormatInt(p, 10))
+		default:
+			return nil, id, fmt.Errorf("%w: arguments must be strings or integers", errInvalidRequest)
+		}
+	}
+	return input, id, nil
+}
+
+func (c *msgpackCodec) writeResponse(id int64, res *Response) error {
+	if res == nil {
+		// Requests are answered even if there is nothing to say.
+		res = &Response{nil}
+	}
+	res = compactResponse(res)
+	var msg []interface{}
+	switch {
+	case (*res)[0] == nil:
+		msg = []interface{}{msgpackResponse, id, nil, nil}
+	case id == noID:
+		msg = []interface{}{msgpackNotification, (*res)[0], []interface{}((*res)[1:])}
+	case (*res)[0] == "error":
+		msg = []interface{}{msgpackResponse, id, (*res)[1], nil}
+	default:
+		msg = []interface{}{msgpackResponse, id, nil, []interface{}(*res)}
+	}
+	c.buf.Reset()
+	c.w.Reset(&c.buf)
+	if err := encodeMsgpack(c.w, msg); err != nil {
+		return err
+	}
+	c.w.Flush()
+	_, err := c.out.Write(c.buf.Bytes())
+	return err
+}
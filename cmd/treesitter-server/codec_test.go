@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	lines := [][]Prop{
+		{
+			{Row: 1, Col: 1, Attr: PropAttr{Length: 7, Type: "TSInclude"}},
+			{Row: 1, Col: 9, Attr: PropAttr{Length: 4, Type: "TSNamespace"}},
+		},
+		{},
+		{
+			{Row: 3, Col: 1, Attr: PropAttr{Length: 0, Type: "TSInclude"}},
+		},
+	}
+	b, err := json.Marshal(compactResponse(&Response{"syntax", lines, "1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `["syntax",{"types":["TSInclude","TSNamespace"],"lines":[[1,7,0,9,4,1],[],[1,0,0]]},"1"]`
+	if string(b) != want {
+		t.Errorf("got %s; want %s", b, want)
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	for _, v := range []interface{}{
+		nil, true, false,
+		int64(0), int64(127), int64(128), int64(-1), int64(-32), int64(-33),
+		int64(70000), int64(-70000), int64(1 << 40),
+		"", "syntax", string(bytes.Repeat([]byte("x"), 40)), string(bytes.Repeat([]byte("x"), 300)), string(bytes.Repeat([]byte("x"), 70000)),
+		[]interface{}{}, []interface{}{int64(1), "a", nil, []interface{}{true}},
+		map[string]interface{}{"lines": []interface{}{int64(1)}, "types": []interface{}{"TSKeyword"}},
+	} {
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		if err := encodeMsgpack(w, v); err != nil {
+			t.Fatal(err)
+		}
+		w.Flush()
+		got, err := decodeMsgpack(bufio.NewReader(&buf), 0)
+		if err != nil {
+			t.Fatalf("%v: %v", v, err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("got %#v; want %#v", got, v)
+		}
+	}
+}
+
+func TestMsgpackSession(t *testing.T) {
+	var in bytes.Buffer
+	w := bufio.NewWriter(&in)
+	encodeMsgpack(w, []interface{}{0, 7, "version", []interface{}{}})
+	encodeMsgpack(w, []interface{}{2, "version", []interface{}{}})
+	encodeMsgpack(w, []interface{}{0, 8, "textobj", []interface{}{"go"}})
+	encodeMsgpack(w, []interface{}{0, 9, "syntax", []interface{}{"nosuchlang", "", 1}})
+	w.Flush()
+
+	var out bytes.Buffer
+	defer func(n int) { workers = n }(workers)
+	workers = 1
+	c, _ := newCodec("msgpack", &in, &out)
+	newSession(c).serve()
+
+	r := bufio.NewReader(&out)
+	var got []interface{}
+	for {
+		v, err := decodeMsgpack(r, 0)
+		if err != nil {
+			break
+		}
+		got = append(got, v)
+	}
+	want := []interface{}{
+		[]interface{}{int64(1), int64(7), nil, []interface{}{"version", version}},
+		[]interface{}{int64(2), "version", []interface{}{version}},
+		[]interface{}{int64(1), int64(8), "textobj: wrong number of arguments", nil},
+		[]interface{}{int64(1), int64(9), nil, nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v; want %#v", got, want)
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestMsgpackWriteError(t *testing.T) {
+	var out bytes.Buffer
+	c, _ := newCodec("msgpack", nil, &out)
+	s := newSession(c)
+	// A value the encoder doesn't know fails halfway through the response,
+	// which is then answered with the error.
+	s.write(3, &Response{"detect", "bash", struct{}{}})
+	s.write(4, &Response{"detect", "bash"})
+
+	r := bufio.NewReader(&out)
+	var got []interface{}
+	for {
+		v, err := decodeMsgpack(r, 0)
+		if err != nil {
+			break
+		}
+		got = append(got, v)
+	}
+	want := []interface{}{
+		[]interface{}{int64(1), int64(3), "msgpack: cannot encode struct {}", nil},
+		[]interface{}{int64(1), int64(4), nil, []interface{}{"detect", "bash"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v; want %#v", got, want)
+	}
+
+	c, _ = newCodec("msgpack", nil, failWriter{})
+	if err := c.writeResponse(5, &Response{"version", version}); err == nil || err.Error() != "broken pipe" {
+		t.Errorf("writeResponse to a broken writer: %v", err)
+	}
+}
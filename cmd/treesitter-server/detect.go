@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// languageAliases maps filetypes of Vim, language identifiers of LSP and
+// other common names to languages.
+var languageAliases = map[string]string{
+	"sh":              "bash",
+	"shellscript":     "bash",
+	"c_sharp":         "csharp",
+	"cs":              "csharp",
+	"containerfile":   "dockerfile",
+	"proto":           "protobuf",
+	"docker":          "dockerfile",
+	"golang":          "go",
+	"terraform":       "hcl",
+	"terraform-vars":  "hcl",
+	"tf":              "hcl",
+	"xhtml":           "html",
+	"javascriptreact": "javascript",
+	"js":              "javascript",
+	"jsx":             "javascript",
+	"python3":         "python",
+	"py":              "python",
+	"rb":              "ruby",
+	"rs":              "rust",
+	"ts":              "typescript",
+	"typescriptreact": "tsx",
+	"yml":             "yaml",
+	"ml":              "ocaml",
+}
+
+var languageExtensions = map[string]string{
+	".bash":       "bash",
+	".sh":         "bash",
+	".c":          "c",
+	".h":          "c",
+	".cc":         "cpp",
+	".cpp":        "cpp",
+	".cxx":        "cpp",
+	".hh":         "cpp",
+	".hpp":        "cpp",
+	".hxx":        "cpp",
+	".cs":         "csharp",
+	".css":        "css",
+	".cue":        "cue",
+	".dockerfile": "dockerfile",
+	".elm":        "elm",
+	".ex":         "elixir",
+	".exs":        "elixir",
+	".go":         "go",
+	".gradle":     "groovy",
+	".groovy":     "groovy",
+	".hcl":        "hcl",
+	".tf":         "hcl",
+	".tfvars":     "hcl",
+	".htm":        "html",
+	".html":       "html",
+	".java":       "java",
+	".json":       "json",
+	".cjs":        "javascript",
+	".js":         "javascript",
+	".jsx":        "javascript",
+	".mjs":        "javascript",
+	".kt":         "kotlin",
+	".kts":        "kotlin",
+	".lua":        "lua",
+	".markdown":   "markdown",
+	".md":         "markdown",
+	".ml":         "ocaml",
+	".mli":        "ocaml",
+	".php":        "php",
+	".proto":      "protobuf",
+	".py":         "python",
+	".pyi":        "python",
+	".gemspec":    "ruby",
+	".rake":       "ruby",
+	".rb":         "ruby",
+	".rs":         "rust",
+	".sc":         "scala",
+	".scala":      "scala",
+	".sql":        "sql",
+	".svelte":     "svelte",
+	".swift":      "swift",
+	".toml":       "toml",
+	".cts":        "typescript",
+	".mts":        "typescript",
+	".ts":         "typescript",
+	".tsx":        "tsx",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+}
+
+var languageFilenames = map[string]string{
+	".bash_profile": "bash",
+	".bashrc":       "bash",
+	".profile":      "bash",
+	"PKGBUILD":      "bash",
+	"Containerfile": "dockerfile",
+	"Dockerfile":    "dockerfile",
+	"Gemfile":       "ruby",
+	"Jenkinsfile":   "groovy",
+	"Rakefile":      "ruby",
+	"Pipfile":       "toml",
+	"Cargo.lock":    "toml",
+}
+
+// languageInterpreters maps the interpreters of shebang lines to languages.
+var languageInterpreters = map[string]string{
+	"ash":     "bash",
+	"bash":    "bash",
+	"dash":    "bash",
+	"ksh":     "bash",
+	"sh":      "bash",
+	"lua":     "lua",
+	"luajit":  "lua",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"ocaml":   "ocaml",
+	"php":     "php",
+	"python":  "python",
+	"python2": "python",
+	"python3": "python",
+	"ruby":    "ruby",
+	"scala":   "scala",
+	"elixir":  "elixir",
+	"deno":    "typescript",
+	"ts-node": "typescript",
+}
+
+// lookupLanguage returns the language of a name or alias, or "".
+func lookupLanguage(s string) string {
+	s = strings.ToLower(s)
+	if registered(s) {
+		return s
+	}
+	if l := languageAliases[s]; registered(l) {
+		return l
+	}
+	return ""
+}
+
+// detectLanguage returns the language of a buffer from its filetype, and
+// when that isn't known from its file name, shebang line or modeline. It
+// returns "" if none of them names a language.
+func detectLanguage(filetype, filename, text string) string {
+	if l := lookupLanguage(filetype); l != "" {
+		return l
+	}
+	// Compound filetypes such as "html.django".
+	for _, ft := range strings.Split(filetype, ".") {
+		if l := lookupLanguage(ft); l != "" {
+			return l
+		}
+	}
+	if filename != "" {
+		base := filepath.Base(filename)
+		if l := languageFilenames[base]; registered(l) {
+			return l
+		}
+		if strings.HasPrefix(base, "Dockerfile.") || strings.HasPrefix(base, "Containerfile.") {
+			return "dockerfile"
+		}
+		if l := languageExtensions[strings.ToLower(filepath.Ext(base))]; registered(l) {
+			return l
+		}
+	}
+	if l := shebangLanguage(text); registered(l) {
+		return l
+	}
+	return modelineLanguage(text)
+}
+
+// registered reports whether the language can be parsed. Some of the
+// names the tables map to are only available as loaded grammars.
+func registered(l string) bool {
+	_, ok := languages[l]
+	return ok
+}
+
+func shebangLanguage(text string) string {
+	if !strings.HasPrefix(text, "#!") {
+		return ""
+	}
+	line := text[2:]
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		fields = fields[1:]
+		for len(fields) > 0 && strings.HasPrefix(fields[0], "-") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			return ""
+		}
+		interp = filepath.Base(fields[0])
+	}
+	if l, ok := languageInterpreters[interp]; ok {
+		return l
+	}
+	// python3.11, lua5.4 and the like
+	if l, ok := languageInterpreters[strings.TrimRight(interp, "0123456789.")]; ok {
+		return l
+	}
+	return ""
+}
+
+var (
+	vimModeline   = regexp.MustCompile(`(?:^|\s)(?:vim?|ex):.*?\b(?:ft|filetype|syntax|syn)=([\w.+-]+)`)
+	emacsModeline = regexp.MustCompile(`-\*-\s*(?:.*?mode:\s*([\w+-]+).*?|([\w+-]+))\s*-\*-`)
+)
+
+// modelineLanguage looks for a Vim or Emacs modeline in the first and last
+// five lines, as Vim does.
+func modelineLanguage(text string) string {
+	lines := strings.SplitN(text, "\n", 6)
+	if len(lines) == 6 {
+		rest := lines[5]
+		lines = lines[:5]
+		for i := 0; i < 5 && rest != ""; i++ {
+			j := strings.LastIndexByte(rest, '\n')
+			lines = append(lines, rest[j+1:])
+			if j < 0 {
+				rest = ""
+			} else {
+				rest = rest[:j]
+			}
+		}
+	}
+	for _, line := range lines {
+		if m := vimModeline.FindStringSubmatch(line); m != nil {
+			if l := lookupLanguage(m[1]); l != "" {
+				return l
+			}
+		}
+		if m := emacsModeline.FindStringSubmatch(line); m != nil {
+			mode := m[1]
+			if mode == "" {
+				mode = m[2]
+			}
+			mode = strings.TrimSuffix(strings.ToLower(mode), "-ts")
+			if l := lookupLanguage(strings.TrimSuffix(mode, "-mode")); l != "" {
+				return l
+			}
+		}
+	}
+	return ""
+}
+
+// detect prints the language of each file.
+func detect(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: %s detect file...\n", name)
+		return 2
+	}
+	status := 0
+	for _, file := range args {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		l := detectLanguage("", file, string(b))
+		if l == "" {
+			fmt.Printf("%s: unknown\n", file)
+			status = 1
+			continue
+		}
+		fmt.Printf("%s: %s\n", file, l)
+	}
+	return status
+}
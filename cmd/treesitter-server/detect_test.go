@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	for _, tt := range []struct {
+		filetype, filename, text string
+		want                     string
+	}{
+		{"go", "", "", "go"},
+		{"sh", "", "", "bash"},
+		{"javascriptreact", "", "", "javascript"},
+		{"typescriptreact", "", "", "tsx"},
+		{"terraform", "", "", "hcl"},
+		{"cs", "", "", "csharp"},
+		{"Dockerfile", "", "", "dockerfile"},
+		{"htmldjango", "index.html", "", "html"},
+		{"yaml.ansible", "", "", "yaml"},
+		{"", "/src/main.tf", "", "hcl"},
+		{"", "/src/run.SH", "", "bash"},
+		{"", "/src/Dockerfile.dev", "", "dockerfile"},
+		{"", "/src/Containerfile", "", "dockerfile"},
+		{"", "Rakefile", "", "ruby"},
+		{"conf", "/bin/tool", "#!/usr/bin/env -S python3.11 -u\nprint(1)\n", "python"},
+		{"", "tool", "#!/bin/sh\n", "bash"},
+		{"", "tool", "#!/usr/bin/env node\n", "javascript"},
+		{"", "tool", "#!/usr/bin/perl\n", ""},
+		{"", "notes", "# vim: set ft=sh :\necho\n", "bash"},
+		{"", "notes", "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n\n// vim: ft=rust\n", "rust"},
+		{"", "notes", "a\nb\nc\nd\ne\nf\n// vim: ft=rust\ng\nh\ni\nj\nk\nl\n", ""},
+		{"", "notes", ";; -*- mode: ruby; coding: utf-8 -*-\n", "ruby"},
+		{"", "notes", "# -*- python -*-\n", "python"},
+		{"text", "notes.txt", "hello\n", ""},
+	} {
+		if got := detectLanguage(tt.filetype, tt.filename, tt.text); got != tt.want {
+			t.Errorf("detectLanguage(%q, %q, %q) = %q; want %q", tt.filetype, tt.filename, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDetectUnregistered(t *testing.T) {
+	// json is only known once its grammar is loaded.
+	if got := detectLanguage("", "package.json", ""); got != "" {
+		t.Errorf("package.json: got %q", got)
+	}
+	if got := detectLanguage("proto", "", ""); got != "protobuf" {
+		t.Errorf("proto: got %q", got)
+	}
+}
@@ -0,0 +1,87 @@
+//go:build !windows
+
+package main
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+typedef const void *(*language_func)(void);
+
+static const void *call_language(void *f) {
+	return ((language_func)f)();
+}
+
+// Part of the tree-sitter runtime linked by the bindings. Its api.h is not
+// on the include path of this package.
+uint32_t ts_language_version(const void *language);
+void *ts_parser_new(void);
+void ts_parser_delete(void *parser);
+_Bool ts_parser_set_language(void *parser, const void *language);
+
+// language_versions finds the range of language versions the runtime
+// accepts, TREE_SITTER_MIN_COMPATIBLE_LANGUAGE_VERSION to
+// TREE_SITTER_LANGUAGE_VERSION, by offering it languages which are all
+// zero but for the version, the first field of TSLanguage.
+static void language_versions(uint32_t *min, uint32_t *max) {
+	static uint32_t language[1024];
+	void *parser = ts_parser_new();
+	*min = *max = 0;
+	for (uint32_t v = 1; v < 256; v++) {
+		language[0] = v;
+		if (ts_parser_set_language(parser, language)) {
+			if (*min == 0) {
+				*min = v;
+			}
+			*max = v;
+		}
+	}
+	ts_parser_set_language(parser, NULL);
+	ts_parser_delete(parser);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// minLanguageVersion and maxLanguageVersion are the language ABI versions
+// the tree-sitter runtime of the bindings accepts.
+var minLanguageVersion, maxLanguageVersion = languageVersions()
+
+func languageVersions() (uint32, uint32) {
+	var min, max C.uint32_t
+	C.language_versions(&min, &max)
+	return uint32(min), uint32(max)
+}
+
+// openLanguage loads the shared library at path and returns the language
+// of the function symbol. The library stays loaded for the life of the
+// process.
+func openLanguage(path, symbol string) (*sitter.Language, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	h := C.dlopen(cpath, C.RTLD_NOW|C.RTLD_LOCAL)
+	if h == nil {
+		return nil, fmt.Errorf("%s", C.GoString(C.dlerror()))
+	}
+	csymbol := C.CString(symbol)
+	defer C.free(unsafe.Pointer(csymbol))
+	f := C.dlsym(h, csymbol)
+	if f == nil {
+		C.dlclose(h)
+		return nil, fmt.Errorf("%s: no symbol %s", path, symbol)
+	}
+	lang := C.call_language(f)
+	if v := uint32(C.ts_language_version(lang)); v < minLanguageVersion || v > maxLanguageVersion {
+		C.dlclose(h)
+		return nil, fmt.Errorf("%s: unsupported language version %d, need %d to %d", path, v, minLanguageVersion, maxLanguageVersion)
+	}
+	return sitter.NewLanguage(unsafe.Pointer(lang)), nil
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"errors"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Grammars are not loaded on Windows, so no language version is accepted.
+var minLanguageVersion, maxLanguageVersion uint32
+
+func openLanguage(path, symbol string) (*sitter.Language, error) {
+	return nil, errors.New(path + ": loading grammars is not supported on Windows")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func FuzzRequest(f *testing.F) {
+	f.Add([]byte(`["version"]`))
+	f.Add([]byte(`["textobj"]`))
+	f.Add([]byte(`["textobj", "go", "package main", "-1", "x"]`))
+	f.Add([]byte(`["syntax", "go", "package main\n"]`))
+	f.Add([]byte(`["syntax"]`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`[1, 2]`))
+	f.Add([]byte(`{"syntax": "go"}`))
+	ps := parsers{}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		input, err := decodeRequest(b)
+		if err != nil {
+			return
+		}
+		handle(context.Background(), ps, input)
+	})
+}
+
+func FuzzMsgpack(f *testing.F) {
+	f.Add([]byte{0x94, 0x00, 0x01, 0xa7, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x90})
+	f.Add([]byte{0x93, 0x02, 0xa6, 's', 'y', 'n', 't', 'a', 'x', 0x91, 0xa2, 'g', 'o'})
+	f.Add([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	f.Add([]byte{0xdb, 0x7f, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		c := &msgpackCodec{r: bufio.NewReader(bytes.NewReader(b)), out: io.Discard}
+		for {
+			if _, _, err := c.readRequest(); err != nil && !errors.Is(err, errInvalidRequest) {
+				return
+			}
+		}
+	})
+}
+
+func FuzzColorizer(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 1, 0, 5})
+	f.Add([]byte{1, 0, 0})
+	f.Add([]byte{0, 0, 2, 0, 1, 0, 1, 2, 3, 1, 2, 4})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		c := NewColorizer(0, 0)
+		for i := 0; i+2 < len(ops); i += 3 {
+			row, col := int(ops[i+1]%8), int(ops[i+2]%16)
+			if ops[i]%2 == 0 {
+				c.Start(groups[int(ops[i])%len(groups)], row, col)
+			} else if err := c.End(row, col); err != nil {
+				return
+			}
+		}
+		for _, line := range c.Render() {
+			last := 0
+			for _, p := range line {
+				if p.Col <= last {
+					t.Fatalf("overlapping props: %v", line)
+				}
+				last = p.Col + p.Attr.Length - 1
+				if p.Attr.Length == EOL {
+					last = int(^uint(0) >> 1)
+				}
+			}
+		}
+	})
+}
+
+func FuzzSyntax(f *testing.F) {
+	names := sortedLanguages()
+	for i, lname := range names {
+		for _, file := range samples(f, lname) {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(i, string(b))
+		}
+	}
+	ps := parsers{}
+	f.Fuzz(func(t *testing.T, i int, code string) {
+		lname := names[uint(i)%uint(len(names))]
+		if _, err := doSyntax(context.Background(), ps, lname, code); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
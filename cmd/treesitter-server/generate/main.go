@@ -1,47 +1,21 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"strings"
-)
 
-func camel(s string) string {
-	isToUpper := false
-	r := ""
-	for k, v := range s {
-		if k == 0 {
-			r = strings.ToUpper(string(s[0]))
-		} else {
-			if isToUpper {
-				r += strings.ToUpper(string(v))
-				isToUpper = false
-			} else {
-				if v == '_' || v == '.' {
-					isToUpper = true
-				} else {
-					r += string(v)
-				}
-			}
-		}
-	}
-	r = strings.Replace(r, "Punctuation", "Punct", -1)
-	if r != "Constant" {
-		r = strings.Replace(r, "Constant", "Const", -1)
-	}
-	return r
-}
+	"github.com/mattn/vim-treesitter/internal/query"
+)
 
 var languages = []string{
 	"bash",
 	"c",
 	"cpp",
-	"csharp",
+	//"csharp",
 	"css",
 	"dockerfile",
 	"ecma",
@@ -82,144 +56,73 @@ func has(m []idmap, name string) bool {
 	return false
 }
 
-func generate(l string) ([]idmap, []idmap, []string) {
-	log.Printf("Generating highlights for %v", l)
-	resp, err := http.Get("https://raw.githubusercontent.com/nvim-treesitter/nvim-treesitter/820b4a9c211a49c878ce3f19ed5c349509e7988f/queries/" + l + "/highlights.scm")
+const baseURL = "https://raw.githubusercontent.com/nvim-treesitter/nvim-treesitter/820b4a9c211a49c878ce3f19ed5c349509e7988f/queries/"
+
+func fetch(base, l string) ([]byte, error) {
+	url := base + l + "/highlights.scm"
+	resp, err := http.Get(url)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
 
-	in := bufio.NewReader(resp.Body)
-
-	var inherits []string
-	bb, err := in.Peek(1)
+func generate(l string, src []byte) ([]idmap, []idmap, []string, error) {
+	log.Printf("Generating highlights for %v", l)
+	q, err := query.Parse(src)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, nil, fmt.Errorf("%v/highlights.scm:%w", l, err)
 	}
-	if bb[0] == ';' {
-		b, _, err := in.ReadLine()
-		if err != nil {
-			log.Fatal(err)
-		}
-		line := string(b)
-		if strings.HasPrefix(line, "; inherits:") {
-			inherits = strings.Split(line[11:], ",")
-			for i, v := range inherits {
-				inherits[i] = strings.TrimSpace(v)
-			}
-			log.Println(inherits)
-		}
+	if len(q.Inherits) > 0 {
+		log.Println(q.Inherits)
 	}
-	p := NewParser(in)
 
 	symbols := []idmap{}
 	keywords := []idmap{}
 
-	var prev *Node
-	for {
-		n, err := p.ParseAny(false)
-		if err != nil {
-			if err == io.EOF {
-				break
+	add := func(n *query.Node, color string) {
+		switch n.Kind {
+		case query.Named:
+			if !has(symbols, n.Name) {
+				symbols = append(symbols, idmap{
+					Name:  n.Name,
+					Color: color,
+				})
+			}
+		case query.Anonymous:
+			if !has(keywords, n.Name) {
+				keywords = append(keywords, idmap{
+					Name:  n.Name,
+					Color: color,
+				})
 			}
-			log.Fatal(err)
 		}
-		if n.t == NodeIdent && n.v.(string)[0] == '@' {
-			if prev.t == NodeCell {
-				if prev.car.t == NodeIdent {
-					name := prev.car.v.(string)
-					if !has(symbols, name) {
-						symbols = append(symbols, idmap{
-							Name:  name,
-							Color: "TS" + camel(n.v.(string)[1:]),
-						})
-					}
-				} else if prev.car.t == NodeNil {
-					name := "nil"
-					if !has(symbols, name) {
-						symbols = append(symbols, idmap{
-							Name:  name,
-							Color: "TS" + camel(n.v.(string)[1:]),
-						})
-					}
-				}
-			} else if prev.t == NodeString {
-				name := prev.v.(string)
-				if !has(keywords, name) {
-					keywords = append(keywords, idmap{
-						Name:  name,
-						Color: "TS" + camel(n.v.(string)[1:]),
-					})
-				}
-			} else if prev.t == NodeArray {
-				curr := prev
-				for curr != nil {
-					if curr.car == nil {
-						break
-					}
-					if curr.car.t == NodeCell && curr.car.car != nil {
-						var name string
-						if curr.car.car.v != nil {
-							name = curr.car.car.v.(string)
-						} else {
-							name = "nil"
-						}
-						if !has(symbols, name) {
-							symbols = append(symbols, idmap{
-								Name:  name,
-								Color: "TS" + camel(n.v.(string)[1:]),
-							})
-						}
-					} else if curr.car.t == NodeString {
-						name := curr.car.v.(string)
-						if !has(keywords, name) {
-							keywords = append(keywords, idmap{
-								Name:  name,
-								Color: "TS" + camel(n.v.(string)[1:]),
-							})
-						}
-					}
-					if curr.cdr == nil || curr.cdr.t == NodeNil {
-						break
-					}
-					curr = curr.cdr
-				}
-			} else {
-				//fmt.Println(n)
+	}
+	for _, n := range q.Patterns {
+		if len(n.Captures) == 0 {
+			continue
+		}
+		color := query.HighlightGroup(n.Captures[0].Name)
+		if n.Kind == query.Alternation {
+			for _, c := range n.Children {
+				add(c, color)
 			}
+		} else {
+			add(n, color)
 		}
-		prev = n
 	}
 
-	return symbols, keywords, inherits
+	return symbols, keywords, q.Inherits, nil
 }
 
-func main() {
-	var fname string
-	flag.StringVar(&fname, "o", "", "output file")
-	flag.Parse()
-
-	var out io.Writer = os.Stdout
-	if fname != "" {
-		f, err := os.Create(fname)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer f.Close()
-		out = f
-	}
-	symbols := map[string][]idmap{}
-	keywords := map[string][]idmap{}
-	inherits := map[string][]string{}
-	for _, l := range languages {
-		s, k, i := generate(l)
-		symbols[l] = s
-		keywords[l] = k
-		inherits[l] = i
-	}
-
-	for _, l := range languages {
+// merge prepends the entries of the inherited languages to the entries of
+// each language. Entries of the language itself don't override them.
+func merge(langs []string, symbols, keywords map[string][]idmap, inherits map[string][]string) {
+	for _, l := range langs {
 		if len(inherits[l]) > 0 {
 			log.Println("Merging", inherits[l])
 			mergedSymbols := []idmap{}
@@ -250,12 +153,14 @@ func main() {
 			keywords[l] = mergedKeywords
 		}
 	}
+}
 
+func emit(out io.Writer, langs []string, symbols, keywords map[string][]idmap) {
 	fmt.Fprintln(out, "package main")
 	fmt.Fprintln(out, "")
 
 	fmt.Fprintln(out, `var symbols = map[string]map[string]string {`)
-	for _, l := range languages {
+	for _, l := range langs {
 		fmt.Fprintf(out, "\t%q: {\n", l)
 		for _, s := range symbols[l] {
 			fmt.Fprintf(out, "\t\t%q: %q,\n", s.Name, s.Color)
@@ -265,7 +170,7 @@ func main() {
 	fmt.Fprintln(out, "}")
 
 	fmt.Fprintln(out, `var keywords = map[string]map[string]string {`)
-	for _, l := range languages {
+	for _, l := range langs {
 		fmt.Fprintf(out, "\t%q: {\n", l)
 		for _, s := range keywords[l] {
 			fmt.Fprintf(out, "\t\t%q: %q,\n", s.Name, s.Color)
@@ -274,3 +179,40 @@ func main() {
 	}
 	fmt.Fprintln(out, "}")
 }
+
+func main() {
+	var fname string
+	flag.StringVar(&fname, "o", "", "output file")
+	flag.Parse()
+
+	var out io.Writer = os.Stdout
+	if fname != "" {
+		f, err := os.Create(fname)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	symbols := map[string][]idmap{}
+	keywords := map[string][]idmap{}
+	inherits := map[string][]string{}
+	for _, l := range languages {
+		src, err := fetch(baseURL, l)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s, k, i, err := generate(l, src)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(s) == 0 && len(k) == 0 && len(i) == 0 {
+			log.Fatalf("%v/highlights.scm: no highlights", l)
+		}
+		symbols[l] = s
+		keywords[l] = k
+		inherits[l] = i
+	}
+	merge(languages, symbols, keywords, inherits)
+	emit(out, languages, symbols, keywords)
+}
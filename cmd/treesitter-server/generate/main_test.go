@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestGenerate(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.scm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var langs []string
+	symbols := map[string][]idmap{}
+	keywords := map[string][]idmap{}
+	inherits := map[string][]string{}
+	for _, file := range files {
+		l := strings.TrimSuffix(filepath.Base(file), ".scm")
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, k, i, err := generate(l, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		langs = append(langs, l)
+		symbols[l] = s
+		keywords[l] = k
+		inherits[l] = i
+	}
+	merge(langs, symbols, keywords, inherits)
+
+	for _, l := range langs {
+		t.Run(l, func(t *testing.T) {
+			var buf bytes.Buffer
+			emit(&buf, []string{l}, symbols, keywords)
+			golden := filepath.Join("testdata", l+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("output differs from %s (run with -update to accept)\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	_, _, _, err := generate("broken", []byte("(identifier) @variable\n\n(call_expression\n"))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if want := "broken/highlights.scm:3:1: unclosed '('"; err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/go/highlights.scm" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "(identifier) @variable\n")
+	}))
+	defer ts.Close()
+
+	b, err := fetch(ts.URL+"/", "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "(identifier) @variable\n" {
+		t.Errorf("got %q", b)
+	}
+	if _, err := fetch(ts.URL+"/", "nosuchlang"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("missing query file: got %v", err)
+	}
+}
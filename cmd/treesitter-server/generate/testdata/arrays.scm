@@ -0,0 +1,17 @@
+[
+  "if"
+  "else"
+] @conditional
+
+[
+  (true)
+  (false)
+] @boolean
+
+[ "(" ")" "[" "]" ] @punctuation.bracket
+
+; Mixed named and anonymous nodes
+[
+  (escape_sequence)
+  "\\"
+] @string.escape
@@ -0,0 +1,9 @@
+; Identifiers
+(identifier) @variable
+(type_identifier) @type
+
+; Keywords
+"func" @keyword.function
+"return" @keyword.return
+
+(comment) @comment
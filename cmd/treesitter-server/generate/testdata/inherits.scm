@@ -0,0 +1,7 @@
+; inherits: base,(arrays)
+
+; base already has @variable for identifiers, so this doesn't win.
+(identifier) @constant
+(field_identifier) @property
+"return" @keyword
+"defer" @keyword
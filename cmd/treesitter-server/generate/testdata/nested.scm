@@ -0,0 +1,19 @@
+; Captures below the top level are not flattened.
+(call_expression
+  function: (identifier) @function)
+
+((identifier) @constant
+ (#match? @constant "^[A-Z][A-Z_0-9]*$"))
+
+(function_declaration
+  name: (identifier) @function) @definition.function
+
+(method_declaration
+  receiver: (parameter_list
+    (parameter_declaration
+      type: (pointer_type (type_identifier) @type)))
+  name: (field_identifier) @method) @method
+
+((comment)+ @comment)
+
+(string_literal (escape_sequence)* @string.escape) @string
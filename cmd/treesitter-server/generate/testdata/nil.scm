@@ -0,0 +1,7 @@
+(nil) @constant.builtin
+
+[
+  (nil)
+  (true)
+  (false)
+] @boolean
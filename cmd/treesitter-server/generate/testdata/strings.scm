@@ -0,0 +1,10 @@
+"\"" @punctuation.delimiter
+"\\" @operator
+"--" @operator
+"=>" @punctuation.special
+"@" @punctuation.special
+"(" @punctuation.bracket
+
+; The first capture of a node wins.
+"--" @comment
+"=>" @punctuation.special @operator
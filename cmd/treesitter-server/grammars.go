@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// grammarPath is the list of directories searched for grammars compiled
+// to shared libraries, <lang>.so (.dylib on macOS), exporting
+// tree_sitter_<lang>. The queries of a grammar go to queries/<lang>/ in
+// the same directory.
+var grammarPath []string
+
+func configDir() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "vim-treesitter")
+}
+
+func defaultGrammarPath() string {
+	if dir := configDir(); dir != "" {
+		return filepath.Join(dir, "grammars")
+	}
+	return ""
+}
+
+// sharedLibraryExt is the file name extension of shared libraries.
+func sharedLibraryExt() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return ".dylib"
+	case "windows":
+		return ".dll"
+	}
+	return ".so"
+}
+
+// languageSymbol returns the name of the function returning the language.
+func languageSymbol(lname string) string {
+	return "tree_sitter_" + strings.ReplaceAll(lname, "-", "_")
+}
+
+// loadGrammars registers the grammars found on the grammar path, which
+// take precedence over the builtin ones of the same name, and returns the
+// directories of their queries. Grammars which fail to load are reported
+// and skipped.
+func loadGrammars() []string {
+	var queryDirs []string
+	ext := sharedLibraryExt()
+	for _, dir := range grammarPath {
+		files, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+		if err != nil || len(files) == 0 {
+			continue
+		}
+		for _, file := range files {
+			lname := strings.TrimSuffix(filepath.Base(file), ext)
+			lang, err := openLanguage(file, languageSymbol(lname))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				continue
+			}
+			languages[lname] = func() *sitter.Language { return lang }
+		}
+		queryDirs = append(queryDirs, filepath.Join(dir, "queries"))
+	}
+	return queryDirs
+}
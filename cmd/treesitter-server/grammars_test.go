@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// buildLibrary compiles a shared library exporting a fake language
+// function. The language is never used, only loaded, so only its version
+// is set.
+func buildLibrary(t *testing.T, dir, lname string, version uint32) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("loading grammars is not supported on Windows")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+	src := filepath.Join(t.TempDir(), "lang.c")
+	code := "static const unsigned int language[32] = {" + fmt.Sprint(version) + "};\nconst void *" + languageSymbol(lname) + "(void) { return language; }\n"
+	if err := os.WriteFile(src, []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, lname+sharedLibraryExt())
+	if b, err := exec.Command(cc, "-shared", "-fPIC", "-o", out, src).CombinedOutput(); err != nil {
+		t.Fatalf("%v\n%s", err, b)
+	}
+}
+
+func TestLoadGrammars(t *testing.T) {
+	dir := t.TempDir()
+	if minLanguageVersion == 0 || minLanguageVersion > maxLanguageVersion {
+		t.Fatalf("language versions %d to %d", minLanguageVersion, maxLanguageVersion)
+	}
+	buildLibrary(t, dir, "dsl", maxLanguageVersion)
+	buildLibrary(t, dir, "oldest", minLanguageVersion)
+	buildLibrary(t, dir, "old", minLanguageVersion-1)
+	buildLibrary(t, dir, "new", maxLanguageVersion+1)
+	if err := os.WriteFile(filepath.Join(dir, "broken"+sharedLibraryExt()), []byte("not a library"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(path []string) { grammarPath = path }(grammarPath)
+	defer delete(languages, "dsl")
+	grammarPath = []string{filepath.Join(dir, "missing"), dir}
+
+	dirs := loadGrammars()
+	if len(dirs) != 1 || dirs[0] != filepath.Join(dir, "queries") {
+		t.Errorf("query dirs = %v", dirs)
+	}
+	defer delete(languages, "oldest")
+	for _, l := range []string{"dsl", "oldest"} {
+		if f, ok := languages[l]; !ok || f() == nil {
+			t.Fatalf("%s was not registered", l)
+		}
+	}
+	for _, l := range []string{"broken", "old", "new"} {
+		if _, ok := languages[l]; ok {
+			t.Errorf("%s was registered", l)
+		}
+	}
+	lib := filepath.Join(dir, "new"+sharedLibraryExt())
+	want := fmt.Sprintf("%s: unsupported language version %d, need %d to %d", lib, maxLanguageVersion+1, minLanguageVersion, maxLanguageVersion)
+	if _, err := openLanguage(lib, languageSymbol("new")); err == nil || err.Error() != want {
+		t.Errorf("opening a grammar of a newer version: %v", err)
+	}
+	if _, err := openLanguage(filepath.Join(dir, "dsl"+sharedLibraryExt()), "tree_sitter_other"); err == nil {
+		t.Error("missing symbol was not reported")
+	}
+}
+
+// writeGrammar writes the sources of a fake grammar of the version.
+func writeGrammar(t *testing.T, version uint32) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "tree-sitter-dsl")
+	for file, content := range map[string]string{
+		"src/parser.c":             "#include \"tree_sitter/parser.h\"\nextern int scanner(void);\nconst void *tree_sitter_dsl(void) { return LANGUAGE; }\n",
+		"src/tree_sitter/parser.h": "static const unsigned int LANGUAGE[32] = {" + fmt.Sprint(version) + "};\n",
+		"src/scanner.c":            "int scanner(void) { return 0; }\n",
+		"queries/highlights.scm":   "(identifier) @variable\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestBuildGrammar(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("loading grammars is not supported on Windows")
+	}
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("no C compiler")
+	}
+	dir := writeGrammar(t, maxLanguageVersion)
+	if name := grammarName(dir); name != "dsl" {
+		t.Errorf("grammarName = %q", name)
+	}
+
+	out := t.TempDir()
+	lib, err := buildGrammar(dir, "dsl", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openLanguage(lib, "tree_sitter_dsl"); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "queries", "dsl", "highlights.scm")); err != nil {
+		t.Error(err)
+	}
+	if _, err := buildGrammar(t.TempDir(), "none", out); err == nil {
+		t.Error("building a directory without a parser succeeded")
+	}
+
+	// A rebuild the runtime can't use leaves the installed grammar alone.
+	stderr := os.Stderr
+	defer func() { os.Stderr = stderr }()
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+	os.Stderr = devnull
+	before, err := os.ReadFile(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer := writeGrammar(t, maxLanguageVersion+1)
+	if err := os.WriteFile(filepath.Join(newer, "queries", "highlights.scm"), []byte("(newer) @variable\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if status := buildGrammarCommand([]string{"-o", out, newer}); status != 1 {
+		t.Errorf("build-grammar of a newer grammar exited with %d", status)
+	}
+	if after, err := os.ReadFile(lib); err != nil || !bytes.Equal(after, before) {
+		t.Errorf("the installed library was replaced: %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(out, "queries", "dsl", "highlights.scm")); err != nil || string(b) != "(identifier) @variable\n" {
+		t.Errorf("the installed queries were replaced: %q, %v", b, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(out, ".*")); len(files) > 0 {
+		t.Errorf("left behind %v", files)
+	}
+	if _, err := openLanguage(lib, "tree_sitter_dsl"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// grepFiles returns the files under the arguments with their languages.
+// Directories, and "dir/..." as in go commands, are walked skipping hidden
+// directories, and their files are searched if the language is known from
+// the file name. Files named explicitly are always searched.
+func grepFiles(args []string, lname string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		root := strings.TrimSuffix(strings.TrimSuffix(arg, "..."), string(filepath.Separator))
+		if root == "" {
+			root = "."
+		}
+		fi, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			if l := detectLanguage("", path, ""); l != "" && (lname == "" || l == lname) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// grepMatch is a capture found by grep.
+type grepMatch struct {
+	line, column int
+	text         string
+}
+
+// syncQueryCache is a queryCache for the workers of grep. Languages which
+// the query doesn't compile for are cached as nil.
+type syncQueryCache struct {
+	mu sync.Mutex
+	queryCache
+	errs map[string]error
+}
+
+func (qs *syncQueryCache) get(ps parsers, lname string) (*highlightQuery, error) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	if err, ok := qs.errs[lname]; ok {
+		return nil, err
+	}
+	hq, err := qs.queryCache.get(ps, lname)
+	if err != nil {
+		qs.errs[lname] = err
+	}
+	return hq, err
+}
+
+// grepFile returns the captures of the matches of the query in file, in
+// the order of the source.
+func grepFile(ps parsers, qs *syncQueryCache, file, lname string) ([]grepMatch, error) {
+	code, l, tree, err := parseSource(ps, file, lname)
+	if err != nil {
+		return nil, err
+	}
+	hq, err := qs.get(ps, l)
+	if err != nil {
+		return nil, err
+	}
+	var matches []grepMatch
+	seen := map[nodeKey]bool{}
+	queryMatches(hq, tree.RootNode(), code, func(m *sitter.QueryMatch) {
+		for _, c := range m.Captures {
+			k := keyOf(c.Node)
+			if seen[k] || strings.HasPrefix(hq.q.CaptureNameForId(c.Index), "_") {
+				continue
+			}
+			seen[k] = true
+			text := c.Node.Content(code)
+			if i := strings.IndexByte(text, '\n'); i >= 0 {
+				text = text[:i]
+			}
+			p := c.Node.StartPoint()
+			matches = append(matches, grepMatch{int(p.Row) + 1, int(p.Column) + 1, text})
+		}
+	})
+	// Matches come in the order patterns end, not in the order of the
+	// source.
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].line != matches[j].line {
+			return matches[i].line < matches[j].line
+		}
+		return matches[i].column < matches[j].column
+	})
+	return matches, nil
+}
+
+func grepCommand(args []string) int {
+	flags := flag.NewFlagSet("grep", flag.ExitOnError)
+	var lname string
+	var list bool
+	flags.StringVar(&lname, "lang", "", "search files of this language only (default: all languages the query works for)")
+	flags.StringVar(&lname, "l", "", "shorthand for -lang")
+	flags.BoolVar(&list, "files", false, "print only the names of files with matches")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s grep [-l lang] [-files] query [path...]\n", name)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	if lname != "" {
+		l := lookupLanguage(lname)
+		if l == "" {
+			fmt.Fprintf(os.Stderr, "grep: %v: %s\n", errUnknownLanguage, lname)
+			return 2
+		}
+		lname = l
+	}
+	paths := flags.Args()[1:]
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := grepFiles(paths, lname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "grep: %v\n", err)
+		return 2
+	}
+	qs := &syncQueryCache{
+		queryCache: queryCache{src: []byte(flags.Arg(0)), queries: map[string]*highlightQuery{}},
+		errs:       map[string]error{},
+	}
+
+	// Files are searched in parallel and printed in order.
+	type result struct {
+		matches []grepMatch
+		err     error
+	}
+	results := make([]chan result, len(files))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	next := make(chan int)
+	n := workers
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		go func() {
+			ps := parserPool.Get().(parsers)
+			defer parserPool.Put(ps)
+			for i := range next {
+				matches, err := grepFile(ps, qs, files[i], lname)
+				results[i] <- result{matches, err}
+			}
+		}()
+	}
+	go func() {
+		for i := range files {
+			next <- i
+		}
+		close(next)
+	}()
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	found, searched, failed := false, false, false
+	for i, file := range files {
+		r := <-results[i]
+		if r.err != nil {
+			// Without -l, files of languages which the query isn't
+			// written for are skipped.
+			if lname == "" && qs.failed(r.err) {
+				continue
+			}
+			w.Flush()
+			fmt.Fprintf(os.Stderr, "grep: %v\n", r.err)
+			failed = true
+			continue
+		}
+		searched = true
+		if len(r.matches) == 0 {
+			continue
+		}
+		found = true
+		if list {
+			fmt.Fprintln(w, file)
+			continue
+		}
+		for _, m := range r.matches {
+			fmt.Fprintf(w, "%s:%d:%d:%s\n", file, m.line, m.column, m.text)
+		}
+	}
+	if !searched && lname == "" {
+		for _, err := range qs.errs {
+			fmt.Fprintf(os.Stderr, "grep: %v\n", err)
+			return 2
+		}
+	}
+	switch {
+	case failed:
+		return 2
+	case !found:
+		return 1
+	}
+	return 0
+}
+
+// failed reports whether err is the error of compiling the query.
+func (qs *syncQueryCache) failed(err error) bool {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	for _, e := range qs.errs {
+		if e == err {
+			return true
+		}
+	}
+	return false
+}
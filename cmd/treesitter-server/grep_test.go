@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	dir := t.TempDir()
+	for name, text := range map[string]string{
+		"a.go":           "package a\n\nfunc f() { log.Fatal(1); t.Fatal(2) }\n",
+		"sub/b.go":       "package b\n\nfunc g() {\n\tlog.Print(3)\n\tlog.Fatal(4)\n}\n",
+		"sub/c.py":       "print(1)\n",
+		".git/d.go":      "package d\n",
+		"sub/README.txt": "log.Fatal\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := grepFiles([]string{dir + "/..."}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "sub/b.go"), filepath.Join(dir, "sub/c.py")}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("files %v; want %v", files, want)
+	}
+	if files, _ := grepFiles([]string{dir}, "go"); len(files) != 2 {
+		t.Errorf("files of go %v", files)
+	}
+
+	qs := &syncQueryCache{
+		queryCache: queryCache{
+			src:     []byte(`(call_expression function: (selector_expression field: (field_identifier) @f (#eq? @f "Fatal")))`),
+			queries: map[string]*highlightQuery{},
+		},
+		errs: map[string]error{},
+	}
+	ps := parsers{}
+	for _, tt := range []struct {
+		file string
+		want []grepMatch
+	}{
+		{"a.go", []grepMatch{{3, 16, "Fatal"}, {3, 28, "Fatal"}}},
+		{"sub/b.go", []grepMatch{{5, 6, "Fatal"}}},
+	} {
+		matches, err := grepFile(ps, qs, filepath.Join(dir, tt.file), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(matches, tt.want) {
+			t.Errorf("%s: %v; want %v", tt.file, matches, tt.want)
+		}
+	}
+	if _, err := grepFile(ps, qs, filepath.Join(dir, "sub/c.py"), ""); err == nil || !qs.failed(err) {
+		t.Errorf("query for python: %v", err)
+	}
+}
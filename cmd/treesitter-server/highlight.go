@@ -65,8 +65,6 @@ var symbols = map[string]map[string]string {
 		"auto": "TSKeyword",
 		"attribute": "TSAttribute",
 	},
-	"csharp": {
-	},
 	"css": {
 		"at_keyword": "TSKeyword",
 		"to": "TSKeyword",
@@ -633,8 +631,6 @@ var keywords = map[string]map[string]string {
 		"delete": "TSKeywordOperator",
 		"::": "TSOperator",
 	},
-	"csharp": {
-	},
 	"css": {
 		"@media": "TSKeyword",
 		"@import": "TSKeyword",
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// htmlOptions are the options of the html command.
+type htmlOptions struct {
+	inline   bool // style attributes instead of classes
+	numbers  bool // line numbers
+	anchors  bool // an id for each line
+	fragment bool // only the <pre> element
+	title    string
+}
+
+// htmlBackground and htmlForeground are the colors of the page, which the
+// default theme is made for.
+const (
+	htmlBackground = "#1c1c1c"
+	htmlForeground = "#d0d0d0"
+)
+
+// styleSheet returns the rules for the classes of the groups of t.
+func styleSheet(t theme) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pre.treesitter { background: %s; color: %s; padding: 1em; }\n", htmlBackground, htmlForeground)
+	b.WriteString("pre.treesitter .lineno { color: #626262; text-decoration: none; user-select: none; }\n")
+	var names []string
+	for group := range t {
+		names = append(names, group)
+	}
+	sort.Strings(names)
+	for _, group := range names {
+		if css := t[group].css(); css != "" {
+			fmt.Fprintf(&b, "pre.treesitter .%s { %s; }\n", group, css)
+		}
+	}
+	return b.String()
+}
+
+// renderHTML writes code as a <pre> element with a <span> for each prop,
+// in a standalone page unless opts.fragment is set.
+func renderHTML(w io.Writer, code string, lines [][]Prop, t theme, opts htmlOptions) {
+	if !opts.fragment {
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(opts.title))
+		if !opts.inline {
+			fmt.Fprintf(w, "<style>\n%s</style>\n", styleSheet(t))
+		}
+		io.WriteString(w, "</head>\n<body>\n")
+	}
+	if opts.inline {
+		fmt.Fprintf(w, `<pre class="treesitter" style="background: %s; color: %s; padding: 1em">`, htmlBackground, htmlForeground)
+	} else {
+		io.WriteString(w, `<pre class="treesitter">`)
+	}
+
+	code = strings.TrimSuffix(code, "\n")
+	all := strings.Split(code, "\n")
+	width := len(fmt.Sprint(len(all)))
+	for i, line := range all {
+		if i > 0 {
+			io.WriteString(w, "\n")
+		}
+		n := i + 1
+		if opts.anchors {
+			fmt.Fprintf(w, `<a id="L%d"></a>`, n)
+		}
+		if opts.numbers {
+			number := fmt.Sprintf("%*d", width, n)
+			attr := `class="lineno"`
+			if opts.inline {
+				attr = `style="color: #626262; text-decoration: none; user-select: none"`
+			}
+			if opts.anchors {
+				fmt.Fprintf(w, `<a %s href="#L%d">%s</a> `, attr, n, number)
+			} else {
+				fmt.Fprintf(w, `<span %s>%s</span> `, attr, number)
+			}
+		}
+		var props []Prop
+		if i < len(lines) {
+			props = lines[i]
+		}
+		spans(line, props, func(text, group string) {
+			text = html.EscapeString(text)
+			switch {
+			case group == "":
+				io.WriteString(w, text)
+			case opts.inline:
+				if css := t[group].css(); css != "" {
+					fmt.Fprintf(w, `<span style="%s">%s</span>`, css, text)
+				} else {
+					io.WriteString(w, text)
+				}
+			default:
+				fmt.Fprintf(w, `<span class="%s">%s</span>`, group, text)
+			}
+		})
+	}
+	io.WriteString(w, "</pre>\n")
+	if !opts.fragment {
+		io.WriteString(w, "</body>\n</html>\n")
+	}
+}
+
+func htmlCommand(args []string) int {
+	fs := flag.NewFlagSet("html", flag.ExitOnError)
+	var lname, themeFile string
+	var opts htmlOptions
+	fs.StringVar(&lname, "lang", "", "language of the file (default: detected)")
+	fs.StringVar(&themeFile, "theme", "", "file with the colors of the highlight groups")
+	fs.BoolVar(&opts.inline, "inline", false, "use style attributes instead of classes")
+	fs.BoolVar(&opts.numbers, "n", false, "number the lines")
+	fs.BoolVar(&opts.anchors, "anchors", false, "add an anchor L<line> to each line")
+	fs.BoolVar(&opts.fragment, "fragment", false, "print only the <pre> element")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s html [flags] [file]\n", name)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+	t, err := loadTheme(themeFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "html: %v\n", err)
+		return 2
+	}
+	file := "-"
+	if fs.NArg() == 1 {
+		file = fs.Arg(0)
+		opts.title = file
+	}
+
+	code, l, err := readSource(file, lname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "html: %v\n", err)
+		return 1
+	}
+	lines, err := doSyntax(context.Background(), parsers{}, l, code)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "html: %v\n", err)
+		return 1
+	}
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	renderHTML(w, code, lines, t, opts)
+	return 0
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML(t *testing.T) {
+	th := theme{"TSKeyword": {fg: "#ff0000", bold: true}, "TSString": {}}
+	lines := [][]Prop{
+		{{Col: 1, Attr: PropAttr{Length: 6, Type: "TSKeyword"}}, {Col: 8, Attr: PropAttr{Length: 5, Type: "TSString"}}},
+		{{Col: 1, Attr: PropAttr{Length: EOL, Type: "TSKeyword"}}},
+	}
+	code := "import \"a<b\"\nend\n"
+	for _, tt := range []struct {
+		opts htmlOptions
+		want string
+	}{
+		{
+			htmlOptions{fragment: true},
+			`<pre class="treesitter"><span class="TSKeyword">import</span> <span class="TSString">&#34;a&lt;b&#34;</span>` + "\n" +
+				`<span class="TSKeyword">end</span></pre>` + "\n",
+		},
+		{
+			htmlOptions{fragment: true, inline: true},
+			`<pre class="treesitter" style="background: #1c1c1c; color: #d0d0d0; padding: 1em">` +
+				`<span style="color: #ff0000; font-weight: bold">import</span> &#34;a&lt;b&#34;` + "\n" +
+				`<span style="color: #ff0000; font-weight: bold">end</span></pre>` + "\n",
+		},
+		{
+			htmlOptions{fragment: true, numbers: true, anchors: true},
+			`<pre class="treesitter"><a id="L1"></a><a class="lineno" href="#L1">1</a> <span class="TSKeyword">import</span> <span class="TSString">&#34;a&lt;b&#34;</span>` + "\n" +
+				`<a id="L2"></a><a class="lineno" href="#L2">2</a> <span class="TSKeyword">end</span></pre>` + "\n",
+		},
+	} {
+		var buf bytes.Buffer
+		renderHTML(&buf, code, lines, th, tt.opts)
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%+v:\ngot  %q\nwant %q", tt.opts, got, tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	renderHTML(&buf, code, lines, th, htmlOptions{title: "<x>"})
+	for _, want := range []string{"<title>&lt;x&gt;</title>", "pre.treesitter .TSKeyword { color: #ff0000; font-weight: bold; }", "</html>\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("page doesn't contain %q:\n%s", want, buf.String())
+		}
+	}
+}
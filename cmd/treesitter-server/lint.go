@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mattn/vim-treesitter/internal/query"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+type problem struct {
+	pos query.Pos
+	msg string
+}
+
+type linter struct {
+	lang   *sitter.Language
+	named  map[string]bool
+	anon   map[string]bool
+	fields map[string]bool
+}
+
+func newLinter(lang *sitter.Language) *linter {
+	l := &linter{
+		lang:   lang,
+		named:  map[string]bool{"ERROR": true},
+		anon:   map[string]bool{},
+		fields: map[string]bool{},
+	}
+	for i := uint32(0); i < lang.SymbolCount(); i++ {
+		s := sitter.Symbol(i)
+		switch lang.SymbolType(s) {
+		case sitter.SymbolTypeRegular:
+			l.named[lang.SymbolName(s)] = true
+		case sitter.SymbolTypeAnonymous:
+			l.anon[lang.SymbolName(s)] = true
+		}
+	}
+	return l
+}
+
+// hasField reports whether the grammar defines the field. The bindings do
+// not expose field names, so ask the query compiler instead.
+func (l *linter) hasField(name string) bool {
+	ok, seen := l.fields[name]
+	if !seen {
+		_, err := sitter.NewQuery([]byte("(_ "+name+": (_))"), l.lang)
+		ok = err == nil
+		l.fields[name] = ok
+	}
+	return ok
+}
+
+func (l *linter) lint(src []byte, highlights bool) []problem {
+	q, err := query.Parse(src)
+	if err != nil {
+		var qe *query.Error
+		if errors.As(err, &qe) {
+			return []problem{{pos: qe.Pos, msg: qe.Msg}}
+		}
+		return []problem{{pos: query.Pos{Line: 1, Column: 1}, msg: err.Error()}}
+	}
+
+	var problems []problem
+	seen := map[string]*query.Node{}
+	for _, pat := range q.Patterns {
+		n := len(problems)
+		captures := pat.CaptureNames()
+		pat.Walk(func(c *query.Node) bool {
+			if c.Field != "" && !l.hasField(c.Field) {
+				problems = append(problems, problem{c.Pos, fmt.Sprintf("unknown field %q", c.Field)})
+			}
+			switch c.Kind {
+			case query.Named:
+				if c.Name != "_" && c.Name != "MISSING" && !l.named[c.Name] {
+					problems = append(problems, problem{c.Pos, fmt.Sprintf("unknown node type %q", c.Name)})
+				}
+			case query.Anonymous:
+				if !l.anon[c.Name] {
+					problems = append(problems, problem{c.Pos, fmt.Sprintf("unknown node type %q", c.Name)})
+				}
+			case query.NegatedField:
+				if !l.hasField(c.Name) {
+					problems = append(problems, problem{c.Pos, fmt.Sprintf("unknown field %q", c.Name)})
+				}
+			}
+			for _, cp := range c.Captures {
+				if !highlights || strings.HasPrefix(cp.Name, "_") {
+					continue
+				}
+				if g := query.HighlightGroup(cp.Name); !has(highlightGroups(), g) {
+					problems = append(problems, problem{cp.Pos, fmt.Sprintf("unknown capture @%s (no highlight group %s)", cp.Name, g)})
+				}
+			}
+			return c.Name != "MISSING"
+		})
+		for _, pred := range pat.AllPredicates() {
+			for _, a := range pred.Args {
+				if a.Capture && !has(captures, a.Value) {
+					problems = append(problems, problem{a.Pos, fmt.Sprintf("predicate #%s references undefined capture @%s", pred.Name, a.Value)})
+				}
+			}
+		}
+
+		key := pat.String()
+		if prev, ok := seen[key]; ok {
+			problems = append(problems, problem{prev.Pos, fmt.Sprintf("unreachable pattern: overridden by the same pattern at %v", pat.Pos)})
+		}
+		seen[key] = pat
+		if len(problems) > n {
+			continue
+		}
+		_, err := sitter.NewQuery(src[pat.Pos.Offset:pat.End.Offset], l.lang)
+		var qe *sitter.QueryError
+		if errors.As(err, &qe) && qe.Type > sitter.QueryErrorCapture {
+			problems = append(problems, problem{pat.Pos, "unreachable pattern: it can never match"})
+		} else if err != nil {
+			problems = append(problems, problem{pat.Pos, err.Error()})
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].pos.Offset < problems[j].pos.Offset
+	})
+	return problems
+}
+
+func queryFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		err := filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && (path == arg || filepath.Ext(path) == ".scm") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func lintQueries(args []string) int {
+	fs := flag.NewFlagSet("lint-queries", flag.ExitOnError)
+	var lname string
+	fs.StringVar(&lname, "lang", "", "language of the queries (default: name of the parent directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s lint-queries [-lang name] file.scm|dir...\n", name)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	files, err := queryFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	linters := map[string]*linter{}
+	status := 0
+	for _, file := range files {
+		l := lname
+		if l == "" {
+			l = filepath.Base(filepath.Dir(file))
+		}
+		lang, err := getLanguage(l)
+		if err != nil {
+			fmt.Printf("%s:1:1: unknown language %q\n", file, l)
+			status = 1
+			continue
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if _, ok := linters[l]; !ok {
+			linters[l] = newLinter(lang)
+		}
+		for _, p := range linters[l].lint(src, filepath.Base(file) == "highlights.scm") {
+			fmt.Printf("%s:%v: %s\n", file, p.pos, p.msg)
+			status = 1
+		}
+	}
+	return status
+}
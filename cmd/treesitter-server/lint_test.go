@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	lang, err := getLanguage("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := newLinter(lang)
+	for _, tt := range []struct {
+		name       string
+		src        string
+		highlights bool
+		want       []string
+	}{
+		{"clean", "(identifier) @variable\n(call_expression function: (identifier) @function.builtin)\n(ERROR) @error\n(identifier) @type\n\"func\" @keyword\n((identifier) @_c (#match? @_c \"^[A-Z]\"))", true, nil},
+		{"syntax error", "(identifier) @variable\n(call_expression", false, []string{"2:1: unclosed '('"}},
+		{"unknown node type", "(identifier)\n(idenitfier)\n\"fn\"", false, []string{
+			`2:1: unknown node type "idenitfier"`,
+			`3:1: unknown node type "fn"`,
+		}},
+		{"unknown field", "(call_expression callee: (identifier))\n(call_expression function: (_) !args)", false, []string{
+			`1:18: unknown field "callee"`,
+			`2:32: unknown field "args"`,
+		}},
+		{"unknown capture", "(identifier) @variabel\n(identifier) @_tmp\n(comment) @comment", true, []string{
+			"1:14: unknown capture @variabel (no highlight group TSVariabel)",
+		}},
+		{"captures outside highlights", "(identifier) @name", false, nil},
+		{"undefined predicate capture", `((identifier) @a (#eq? @b "x"))`, false, []string{
+			"1:24: predicate #eq? references undefined capture @b",
+		}},
+		{"duplicate pattern", "(identifier) @a\n(comment)\n(identifier)   @a", false, []string{
+			"1:1: unreachable pattern: overridden by the same pattern at 3:1",
+		}},
+		{"impossible pattern", "(call_expression (package_clause))", false, []string{
+			"1:1: unreachable pattern: it can never match",
+		}},
+	} {
+		var got []string
+		for _, p := range l.lint([]byte(tt.src), tt.highlights) {
+			got = append(got, p.pos.String()+": "+p.msg)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestLintQueries(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"good/go/highlights.scm": "(identifier) @variable\n",
+		"bad/go/highlights.scm":  "(identifier) @variabel\n",
+		"bad/nolang/folds.scm":   "(block)\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdout, stderr := os.Stdout, os.Stderr
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+	os.Stdout, os.Stderr = devnull, devnull
+
+	for _, tt := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{filepath.Join(dir, "good")}, 0},
+		{[]string{"queries"}, 0},
+		{[]string{filepath.Join(dir, "bad", "go")}, 1},
+		{[]string{filepath.Join(dir, "bad", "nolang")}, 1},
+		{[]string{"-lang", "go", filepath.Join(dir, "bad", "nolang")}, 0},
+		{[]string{filepath.Join(dir, "missing")}, 2},
+		{nil, 2},
+	} {
+		if got := lintQueries(tt.args); got != tt.want {
+			t.Errorf("lintQueries(%q) = %d; want %d", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// listenAddr splits an address given to -listen into a network and an
+// address: "unix:/path/to/socket" or "host:port" for TCP. A TCP address
+// without a host is on the loopback interface, since clients aren't
+// authenticated.
+func listenAddr(addr string) (string, string) {
+	if strings.HasPrefix(addr, "unix:") {
+		return "unix", strings.TrimPrefix(addr, "unix:")
+	}
+	if strings.HasPrefix(addr, ":") {
+		return "tcp", "127.0.0.1" + addr
+	}
+	return "tcp", addr
+}
+
+// listen serves every client connecting to addr with its own session until
+// the process is interrupted. Language objects and compiled queries are
+// shared by all clients.
+func listen(addr string) error {
+	network, address := listenAddr(addr)
+	if network == "unix" {
+		// A socket left behind by a killed server would make Listen fail.
+		if c, err := net.Dial(network, address); err == nil {
+			c.Close()
+			return fmt.Errorf("%s is already in use", address)
+		}
+		if fi, err := os.Lstat(address); err == nil {
+			if fi.Mode()&os.ModeSocket == 0 {
+				return fmt.Errorf("%s is in use and not a socket", address)
+			}
+			os.Remove(address)
+		}
+	}
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	if a, ok := l.Addr().(*net.TCPAddr); ok && !a.IP.IsLoopback() {
+		fmt.Fprintf(os.Stderr, "%s: warning: %v is reachable from other hosts and clients aren't authenticated\n", name, a)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Close()
+	}()
+	return serveListener(l)
+}
+
+// serveListener serves the clients accepted by l until it is closed.
+func serveListener(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			c, _ := newCodec(format, conn, conn)
+			newSession(c).serve()
+		}()
+	}
+}
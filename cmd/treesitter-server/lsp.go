@@ -0,0 +1,656 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// The LSP front-end serves what can be computed from the trees: semantic
+// tokens from the highlighting, folding and selection ranges, document
+// symbols and diagnostics for syntax errors.
+
+type lspRequest struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lspError) Error() string {
+	return e.Message
+}
+
+type lspErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   lspError         `json:"error"`
+}
+
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+const (
+	lspParseError     = -32700
+	lspInvalidParams  = -32602
+	lspMethodNotFound = -32601
+	lspInternalError  = -32603
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextDocument struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Text       string `json:"text"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspFoldingRange struct {
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+	Kind      string `json:"kind,omitempty"`
+}
+
+type lspSelectionRange struct {
+	Range  lspRange           `json:"range"`
+	Parent *lspSelectionRange `json:"parent,omitempty"`
+}
+
+type lspDocumentSymbol struct {
+	Name           string              `json:"name"`
+	Kind           int                 `json:"kind"`
+	Range          lspRange            `json:"range"`
+	SelectionRange lspRange            `json:"selectionRange"`
+	Children       []lspDocumentSymbol `json:"children,omitempty"`
+}
+
+// readMessage reads the content of a message framed with a Content-Length
+// header.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	h, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(h.Get("Content-Length"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid Content-Length: %q", h.Get("Content-Length"))
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func writeMessage(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	return err
+}
+
+// uriPath returns the path of a file URI, or "" for other URIs.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return u.Path
+}
+
+// document is an open text document and its latest tree.
+type document struct {
+	lname string
+	lang  *sitter.Language
+	text  []byte
+	lines [][]byte
+	tree  *sitter.Tree
+}
+
+func newDocument(text string) *document {
+	d := &document{text: []byte(text)}
+	d.lines = bytes.Split(d.text, []byte("\n"))
+	return d
+}
+
+func (d *document) line(row int) []byte {
+	if row < 0 || row >= len(d.lines) {
+		return nil
+	}
+	return d.lines[row]
+}
+
+// utf16Len returns the length of b in UTF-16 code units, the unit of the
+// columns of LSP.
+func utf16Len(b []byte) int {
+	n := 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+		b = b[size:]
+	}
+	return n
+}
+
+func (d *document) position(p sitter.Point) lspPosition {
+	line := d.line(int(p.Row))
+	col := int(p.Column)
+	if col > len(line) {
+		col = len(line)
+	}
+	return lspPosition{Line: int(p.Row), Character: utf16Len(line[:col])}
+}
+
+func (d *document) point(p lspPosition) sitter.Point {
+	line := d.line(p.Line)
+	col, n := 0, 0
+	for col < len(line) && n < p.Character {
+		r, size := utf8.DecodeRune(line[col:])
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+		col += size
+	}
+	return sitter.Point{Row: uint32(p.Line), Column: uint32(col)}
+}
+
+func (d *document) nodeRange(n *sitter.Node) lspRange {
+	return lspRange{Start: d.position(n.StartPoint()), End: d.position(n.EndPoint())}
+}
+
+// semanticTokenTypes maps highlight groups to the standard token types of
+// LSP. Groups without an equivalent are passed on under their own name.
+var semanticTokenTypes = map[string]string{
+	"TSAnnotation":         "decorator",
+	"TSAttribute":          "decorator",
+	"TSBoolean":            "keyword",
+	"TSCharacter":          "string",
+	"TSComment":            "comment",
+	"TSConditional":        "keyword",
+	"TSConstBuiltin":       "variable",
+	"TSConstMacro":         "macro",
+	"TSConstant":           "variable",
+	"TSConstructor":        "class",
+	"TSException":          "keyword",
+	"TSField":              "property",
+	"TSFloat":              "number",
+	"TSFuncBuiltin":        "function",
+	"TSFuncMacro":          "macro",
+	"TSFunction":           "function",
+	"TSFunctionBuiltin":    "function",
+	"TSFunctionMacro":      "macro",
+	"TSInclude":            "keyword",
+	"TSKeyword":            "keyword",
+	"TSKeywordFunction":    "keyword",
+	"TSKeywordOperator":    "keyword",
+	"TSKeywordReturn":      "keyword",
+	"TSMethod":             "method",
+	"TSNamespace":          "namespace",
+	"TSNumber":             "number",
+	"TSOperator":           "operator",
+	"TSParameter":          "parameter",
+	"TSParameterReference": "parameter",
+	"TSProperty":           "property",
+	"TSRepeat":             "keyword",
+	"TSString":             "string",
+	"TSStringEscape":       "string",
+	"TSStringRegex":        "regexp",
+	"TSStringSpecial":      "string",
+	"TSType":               "type",
+	"TSTypeBuiltin":        "type",
+	"TSVariable":           "variable",
+	"TSVariableBuiltin":    "variable",
+}
+
+var semanticTokenModifiers = []string{"readonly", "defaultLibrary"}
+
+var groupModifiers = map[string]int{
+	"TSConstant":        1,
+	"TSConstBuiltin":    1 | 2,
+	"TSFuncBuiltin":     2,
+	"TSFunctionBuiltin": 2,
+	"TSTypeBuiltin":     2,
+	"TSVariableBuiltin": 2,
+}
+
+// semanticTokenLegend returns the token types in the order of their
+// indexes and the index of each highlight group.
+func semanticTokenLegend() ([]string, map[string]int) {
+	var types []string
+	index := map[string]int{}
+	for _, g := range highlightGroups() {
+		t, ok := semanticTokenTypes[g]
+		if !ok {
+			t = g
+		}
+		i := -1
+		for j, u := range types {
+			if u == t {
+				i = j
+			}
+		}
+		if i < 0 {
+			i = len(types)
+			types = append(types, t)
+		}
+		index[g] = i
+	}
+	return types, index
+}
+
+// semanticTokens encodes the props of the lines from start to end as
+// relative semantic tokens.
+func (d *document) semanticTokens(lines [][]Prop, start, end int, index map[string]int) []int {
+	data := []int{}
+	prevLine, prevChar := 0, 0
+	for _, props := range lines {
+		for _, p := range props {
+			row := p.Row - 1
+			if row < start || row > end {
+				continue
+			}
+			line := d.line(row)
+			from := p.Col - 1
+			to := from + p.Attr.Length
+			if p.Attr.Length == EOL || to > len(line) {
+				to = len(line)
+			}
+			t, ok := index[p.Attr.Type]
+			if from >= to || !ok {
+				continue
+			}
+			char := utf16Len(line[:from])
+			length := utf16Len(line[from:to])
+			if row != prevLine {
+				prevChar = 0
+			}
+			data = append(data, row-prevLine, char-prevChar, length, t, groupModifiers[p.Attr.Type])
+			prevLine, prevChar = row, char
+		}
+	}
+	return data
+}
+
+func (d *document) foldingRanges() []lspFoldingRange {
+	ranges := []lspFoldingRange{}
+	seen := map[int]bool{}
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			c := n.NamedChild(i)
+			start, end := int(c.StartPoint().Row), int(c.EndPoint().Row)
+			if c.EndPoint().Column == 0 {
+				end--
+			}
+			if end > start && !seen[start] {
+				seen[start] = true
+				r := lspFoldingRange{StartLine: start, EndLine: end}
+				if strings.Contains(c.Type(), "comment") {
+					r.Kind = "comment"
+				}
+				ranges = append(ranges, r)
+			}
+			walk(c)
+		}
+	}
+	walk(d.tree.RootNode())
+	return ranges
+}
+
+func (d *document) selectionRange(p lspPosition) lspSelectionRange {
+	pt := d.point(p)
+	n := d.tree.RootNode().NamedDescendantForPointRange(pt, pt)
+	var chain []lspRange
+	for ; n != nil; n = n.Parent() {
+		r := d.nodeRange(n)
+		if len(chain) == 0 || chain[len(chain)-1] != r {
+			chain = append(chain, r)
+		}
+	}
+	if len(chain) == 0 {
+		return lspSelectionRange{Range: lspRange{Start: p, End: p}}
+	}
+	var sr *lspSelectionRange
+	for i := len(chain) - 1; i >= 0; i-- {
+		sr = &lspSelectionRange{Range: chain[i], Parent: sr}
+	}
+	return *sr
+}
+
+// symbolNode matches the types of nodes which define a symbol when they
+// have a name.
+var symbolNode = regexp.MustCompile(`declaration|definition|_item$|_spec$|^class|^function|^method|^module`)
+
+var symbolKinds = []struct {
+	s    string
+	kind int
+}{
+	{"method", 6},
+	{"constructor", 9},
+	{"func", 12},
+	{"class", 5},
+	{"interface", 11},
+	{"trait", 11},
+	{"struct", 23},
+	{"enum", 10},
+	{"mod", 2},
+	{"namespace", 3},
+	{"package", 4},
+	{"field", 8},
+	{"const", 14},
+	{"type", 5},
+}
+
+func symbolKind(typ string) int {
+	for _, k := range symbolKinds {
+		if strings.Contains(typ, k.s) {
+			return k.kind
+		}
+	}
+	return 13 // Variable
+}
+
+func (d *document) symbols() []lspDocumentSymbol {
+	var walk func(n *sitter.Node) []lspDocumentSymbol
+	walk = func(n *sitter.Node) []lspDocumentSymbol {
+		symbols := []lspDocumentSymbol{}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			c := n.NamedChild(i)
+			name := c.ChildByFieldName("name")
+			if name == nil || !symbolNode.MatchString(c.Type()) {
+				symbols = append(symbols, walk(c)...)
+				continue
+			}
+			symbols = append(symbols, lspDocumentSymbol{
+				Name:           name.Content(d.text),
+				Kind:           symbolKind(c.Type()),
+				Range:          d.nodeRange(c),
+				SelectionRange: d.nodeRange(name),
+				Children:       walk(c),
+			})
+		}
+		return symbols
+	}
+	return walk(d.tree.RootNode())
+}
+
+func (d *document) diagnostics() []lspDiagnostic {
+	diags := []lspDiagnostic{}
+	if d.tree == nil {
+		return diags
+	}
+	for _, n := range syntaxErrors(d.tree.RootNode()) {
+		diags = append(diags, lspDiagnostic{Range: d.nodeRange(n), Severity: 1, Source: name, Message: syntaxErrorMessage(n)})
+	}
+	return diags
+}
+
+type lspServer struct {
+	wmu sync.Mutex
+	w   io.Writer
+
+	ps       parsers
+	docs     map[string]*document
+	index    map[string]int
+	shutdown bool
+}
+
+func (s *lspServer) send(v interface{}) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	writeMessage(s.w, v)
+}
+
+func (s *lspServer) reply(id *json.RawMessage, result interface{}, err error) {
+	if err != nil {
+		code := lspInternalError
+		var le *lspError
+		if errors.As(err, &le) {
+			code = le.Code
+		}
+		s.send(lspErrorResponse{JSONRPC: "2.0", ID: id, Error: lspError{Code: code, Message: err.Error()}})
+		return
+	}
+	s.send(lspResponse{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+// open parses the text of a document and publishes its syntax errors.
+func (s *lspServer) open(uri, lname, text string) {
+	d := newDocument(text)
+	d.lname = lname
+	s.docs[uri] = d
+	if parser, lang, err := s.ps.get(lname); err == nil {
+		d.lang = lang
+		d.tree, _ = parse(context.Background(), parser, d.text)
+	}
+	s.publish(uri, d.diagnostics())
+}
+
+func (s *lspServer) publish(uri string, diags []lspDiagnostic) {
+	s.send(lspNotification{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diags,
+	}})
+}
+
+func (s *lspServer) document(params json.RawMessage) (*document, error) {
+	var p struct {
+		TextDocument lspTextDocument `json:"textDocument"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+	}
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, &lspError{Code: lspInvalidParams, Message: "unknown document: " + p.TextDocument.URI}
+	}
+	return d, nil
+}
+
+// handle runs a request and returns its result. Notifications return nil.
+func (s *lspServer) handle(req *lspRequest) (interface{}, error) {
+	switch req.Method {
+	case "initialize":
+		types, _ := semanticTokenLegend()
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": 1, // Full
+				"semanticTokensProvider": map[string]interface{}{
+					"legend": map[string]interface{}{
+						"tokenTypes":     types,
+						"tokenModifiers": semanticTokenModifiers,
+					},
+					"full":  true,
+					"range": true,
+				},
+				"foldingRangeProvider":   true,
+				"selectionRangeProvider": true,
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]string{"name": name, "version": version},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, err
+		}
+		lname := detectLanguage(p.TextDocument.LanguageID, uriPath(p.TextDocument.URI), p.TextDocument.Text)
+		s.open(p.TextDocument.URI, lname, p.TextDocument.Text)
+	case "textDocument/didChange":
+		var p struct {
+			TextDocument   lspTextDocument `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, err
+		}
+		d, ok := s.docs[p.TextDocument.URI]
+		if !ok || len(p.ContentChanges) == 0 {
+			return nil, nil
+		}
+		s.open(p.TextDocument.URI, d.lname, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var p struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		s.publish(p.TextDocument.URI, []lspDiagnostic{})
+	case "textDocument/semanticTokens/full", "textDocument/semanticTokens/range":
+		d, err := s.document(req.Params)
+		if err != nil || d.tree == nil {
+			return nil, err
+		}
+		start, end := 0, len(d.lines)
+		if strings.HasSuffix(req.Method, "/range") {
+			var p struct {
+				Range lspRange `json:"range"`
+			}
+			if err := json.Unmarshal(req.Params, &p); err != nil {
+				return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+			}
+			start, end = p.Range.Start.Line, p.Range.End.Line
+		}
+		lines, err := highlightTree(context.Background(), d.lname, d.lang, d.tree.RootNode(), d.text)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"data": d.semanticTokens(lines, start, end, s.index)}, nil
+	case "textDocument/foldingRange":
+		d, err := s.document(req.Params)
+		if err != nil || d.tree == nil {
+			return nil, err
+		}
+		return d.foldingRanges(), nil
+	case "textDocument/selectionRange":
+		d, err := s.document(req.Params)
+		if err != nil || d.tree == nil {
+			return nil, err
+		}
+		var p struct {
+			Positions []lspPosition `json:"positions"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		ranges := []lspSelectionRange{}
+		for _, pos := range p.Positions {
+			ranges = append(ranges, d.selectionRange(pos))
+		}
+		return ranges, nil
+	case "textDocument/documentSymbol":
+		d, err := s.document(req.Params)
+		if err != nil || d.tree == nil {
+			return nil, err
+		}
+		return d.symbols(), nil
+	default:
+		if req.ID != nil {
+			return nil, &lspError{Code: lspMethodNotFound, Message: "method not found: " + req.Method}
+		}
+	}
+	return nil, nil
+}
+
+// safeHandle is handle which turns a panic into an error.
+func (s *lspServer) safeHandle(req *lspRequest) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.ps.reset()
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return s.handle(req)
+}
+
+// serveLSP speaks the Language Server Protocol on r and w until the client
+// sends exit. It returns the exit status the protocol asks for.
+func serveLSP(r io.Reader, w io.Writer) int {
+	_, index := semanticTokenLegend()
+	s := &lspServer{
+		w:     w,
+		ps:    parsers{},
+		docs:  map[string]*document{},
+		index: index,
+	}
+	reader := bufio.NewReader(r)
+	for {
+		b, err := readMessage(reader)
+		if err != nil {
+			return 1
+		}
+		var req lspRequest
+		if err := json.Unmarshal(b, &req); err != nil {
+			s.reply(nil, nil, &lspError{Code: lspParseError, Message: err.Error()})
+			continue
+		}
+		if req.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		result, err := s.safeHandle(&req)
+		if req.ID != nil {
+			s.reply(req.ID, result, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+func TestDocumentPosition(t *testing.T) {
+	d := newDocument("a := \"é😀x\"\nb")
+	for _, tt := range []struct {
+		pt  sitter.Point
+		pos lspPosition
+	}{
+		{sitter.Point{Row: 0, Column: 0}, lspPosition{Line: 0, Character: 0}},
+		{sitter.Point{Row: 0, Column: 6}, lspPosition{Line: 0, Character: 6}},
+		{sitter.Point{Row: 0, Column: 8}, lspPosition{Line: 0, Character: 7}},
+		{sitter.Point{Row: 0, Column: 12}, lspPosition{Line: 0, Character: 9}},
+		{sitter.Point{Row: 1, Column: 1}, lspPosition{Line: 1, Character: 1}},
+	} {
+		if got := d.position(tt.pt); got != tt.pos {
+			t.Errorf("position(%v) = %v; want %v", tt.pt, got, tt.pos)
+		}
+		if got := d.point(tt.pos); got != tt.pt {
+			t.Errorf("point(%v) = %v; want %v", tt.pos, got, tt.pt)
+		}
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	d := newDocument("func é() {\n}\n// x\nvar v = len")
+	types, index := semanticTokenLegend()
+	lines := [][]Prop{
+		{
+			{Row: 1, Col: 1, Attr: PropAttr{Length: 4, Type: "TSKeywordFunction"}},
+			{Row: 1, Col: 6, Attr: PropAttr{Length: 2, Type: "TSFunction"}},
+		},
+		{},
+		{
+			{Row: 3, Col: 1, Attr: PropAttr{Length: EOL, Type: "TSComment"}},
+		},
+		{
+			{Row: 4, Col: 1, Attr: PropAttr{Length: 3, Type: "TSNoSuchGroup"}},
+			{Row: 4, Col: 5, Attr: PropAttr{Length: 1, Type: "TSVariable"}},
+			{Row: 4, Col: 9, Attr: PropAttr{Length: 3, Type: "TSFunctionBuiltin"}},
+		},
+	}
+	got := d.semanticTokens(lines, 0, 3, index)
+	want := []int{
+		0, 0, 4, index["TSKeywordFunction"], 0,
+		0, 5, 1, index["TSFunction"], 0,
+		2, 0, 4, index["TSComment"], 0,
+		1, 4, 1, index["TSVariable"], 0,
+		0, 4, 3, index["TSFunctionBuiltin"], 2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	if got := d.semanticTokens(lines, 2, 2, index); !reflect.DeepEqual(got, []int{2, 0, 4, index["TSComment"], 0}) {
+		t.Errorf("range: got %v", got)
+	}
+
+	for g, want := range map[string]string{
+		"TSComment":         "comment",
+		"TSTag":             "TSTag",
+		"TSVariable":        "variable",
+		"TSFunctionBuiltin": "function",
+		"TSFunctionMacro":   "macro",
+		"TSError":           "TSError",
+	} {
+		if got := types[index[g]]; got != want {
+			t.Errorf("%s has token type %q; want %q", g, got, want)
+		}
+	}
+	// Every group the highlighter produces has a token type.
+	for _, m := range []map[string]map[string]string{symbols, keywords} {
+		for lname, types := range m {
+			for _, g := range types {
+				if _, ok := index[g]; !ok {
+					t.Errorf("%s: %s is not in the legend", lname, g)
+				}
+			}
+		}
+	}
+}
+
+func TestServeLSP(t *testing.T) {
+	var in bytes.Buffer
+	for _, msg := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	} {
+		writeMessage(&in, json.RawMessage(msg))
+	}
+	var out bytes.Buffer
+	if status := serveLSP(&in, &out); status != 0 {
+		t.Errorf("exit status %d", status)
+	}
+
+	r := bufio.NewReader(&out)
+	var got []string
+	for {
+		b, err := readMessage(r)
+		if err != nil {
+			break
+		}
+		got = append(got, string(b))
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d responses: %v", len(got), got)
+	}
+	if !strings.Contains(got[0], `"semanticTokensProvider"`) {
+		t.Errorf("initialize: %s", got[0])
+	}
+	if !strings.Contains(got[1], `"code":-32601`) {
+		t.Errorf("unknown method: %s", got[1])
+	}
+	if got[2] != `{"jsonrpc":"2.0","id":3,"result":null}` {
+		t.Errorf("shutdown: %s", got[2])
+	}
+}
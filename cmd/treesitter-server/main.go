@@ -1,16 +1,18 @@
 package main
 
-//go:generate go run generate/main.go generate/parser.go -o highlight.go
+//go:generate go run ./generate -o highlight.go
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
-	"strconv"
+	"sort"
+	"sync"
+	"time"
 
 	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/smacker/go-tree-sitter/bash"
@@ -18,21 +20,29 @@ import (
 	"github.com/smacker/go-tree-sitter/cpp"
 	"github.com/smacker/go-tree-sitter/csharp"
 	"github.com/smacker/go-tree-sitter/css"
+	"github.com/smacker/go-tree-sitter/cue"
 	"github.com/smacker/go-tree-sitter/dockerfile"
+	"github.com/smacker/go-tree-sitter/elixir"
 	"github.com/smacker/go-tree-sitter/elm"
 	"github.com/smacker/go-tree-sitter/golang"
+	"github.com/smacker/go-tree-sitter/groovy"
 	"github.com/smacker/go-tree-sitter/hcl"
 	"github.com/smacker/go-tree-sitter/html"
 	"github.com/smacker/go-tree-sitter/java"
 	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/kotlin"
 	"github.com/smacker/go-tree-sitter/lua"
+	markdown "github.com/smacker/go-tree-sitter/markdown/tree-sitter-markdown"
 	"github.com/smacker/go-tree-sitter/ocaml"
 	"github.com/smacker/go-tree-sitter/php"
+	"github.com/smacker/go-tree-sitter/protobuf"
 	"github.com/smacker/go-tree-sitter/python"
 	"github.com/smacker/go-tree-sitter/ruby"
 	"github.com/smacker/go-tree-sitter/rust"
 	"github.com/smacker/go-tree-sitter/scala"
+	"github.com/smacker/go-tree-sitter/sql"
 	"github.com/smacker/go-tree-sitter/svelte"
+	"github.com/smacker/go-tree-sitter/swift"
 	"github.com/smacker/go-tree-sitter/toml"
 	"github.com/smacker/go-tree-sitter/typescript/tsx"
 	"github.com/smacker/go-tree-sitter/typescript/typescript"
@@ -65,27 +75,76 @@ var languages = map[string]func() *sitter.Language{
 	"cpp":        cpp.GetLanguage,
 	"csharp":     csharp.GetLanguage,
 	"css":        css.GetLanguage,
+	"cue":        cue.GetLanguage,
 	"dockerfile": dockerfile.GetLanguage,
+	"elixir":     elixir.GetLanguage,
 	"elm":        elm.GetLanguage,
 	"go":         golang.GetLanguage,
+	"groovy":     groovy.GetLanguage,
 	"hcl":        hcl.GetLanguage,
 	"html":       html.GetLanguage,
 	"java":       java.GetLanguage,
 	"javascript": javascript.GetLanguage,
+	"kotlin":     kotlin.GetLanguage,
 	"lua":        lua.GetLanguage,
+	"markdown":   markdown.GetLanguage,
 	"ocaml":      ocaml.GetLanguage,
 	"php":        php.GetLanguage,
+	"protobuf":   protobuf.GetLanguage,
 	"python":     python.GetLanguage,
 	"ruby":       ruby.GetLanguage,
 	"rust":       rust.GetLanguage,
 	"scala":      scala.GetLanguage,
+	"sql":        sql.GetLanguage,
 	"svelte":     svelte.GetLanguage,
+	"swift":      swift.GetLanguage,
 	"toml":       toml.GetLanguage,
-	"typescript": typescript.GetLanguage,
 	"tsx":        tsx.GetLanguage,
+	"typescript": typescript.GetLanguage,
 	"yaml":       yaml.GetLanguage,
 }
 
+// groups are the highlight groups the Vim plugin defines prop types for.
+var groups = []string{
+	"TSAnnotation", "TSAttribute", "TSBoolean", "TSCharacter", "TSComment",
+	"TSConditional", "TSConstBuiltin", "TSConstMacro", "TSConstant",
+	"TSConstructor", "TSDanger", "TSEmphasis", "TSEnvironment",
+	"TSEnvironmentName", "TSException", "TSField", "TSFloat", "TSFuncBuiltin",
+	"TSFuncMacro", "TSFunction", "TSInclude", "TSKeyword", "TSKeywordFunction",
+	"TSKeywordOperator", "TSKeywordReturn", "TSLabel", "TSLiteral", "TSMath",
+	"TSMethod", "TSNamespace", "TSNone", "TSNote", "TSNumber", "TSOperator",
+	"TSParameter", "TSParameterReference", "TSProperty", "TSPunctBracket",
+	"TSPunctDelimiter", "TSPunctSpecial", "TSRepeat", "TSStrike", "TSString",
+	"TSStringEscape", "TSStringRegex", "TSStringSpecial", "TSStrong",
+	"TSSymbol", "TSTag", "TSTagAttribute", "TSTagDelimiter", "TSText",
+	"TSTextReference", "TSTitle", "TSType", "TSTypeBuiltin", "TSURI",
+	"TSUnderline", "TSVariableBuiltin", "TSWarning",
+}
+
+// builtinGroups are the groups which only the generated symbols and
+// keywords maps produce.
+var builtinGroups = func() []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, m := range []map[string]map[string]string{symbols, keywords} {
+		for _, types := range m {
+			for _, g := range types {
+				if !seen[g] && !has(groups, g) {
+					seen[g] = true
+					result = append(result, g)
+				}
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}()
+
+// highlightGroups returns every group the highlighter produces.
+func highlightGroups() []string {
+	return append(append([]string{}, groups...), builtinGroups...)
+}
+
 func has(kw []string, s string) bool {
 	for _, k := range kw {
 		if k == s {
@@ -95,7 +154,7 @@ func has(kw []string, s string) bool {
 	return false
 }
 
-type Response [2]interface{}
+type Response []interface{}
 
 type Point struct {
 	Row    uint32 `json:"row"`
@@ -163,9 +222,13 @@ func (c *Colorizer) Start(color string, row, column int) {
 	c.colors = append([]string{color}, c.colors...)
 }
 
-func (c *Colorizer) End(row, column int) {
+func (c *Colorizer) End(row, column int) error {
+	if len(c.colors) < 2 {
+		return errors.New("colorizer: End without Start")
+	}
 	c.AdvanceTo(row, column)
 	c.colors = c.colors[1:]
+	return nil
 }
 
 type PropAttr struct {
@@ -186,7 +249,9 @@ func (c *Colorizer) Render() [][]Prop {
 		col := 1
 		for j := len(*(c.lines[i])) - 1; j >= 0; j-- {
 			v := (*(c.lines[i]))[j]
-			props = append(props, Prop{Row: i + 1, Col: col, Attr: v.Attr})
+			if v.Attr.Type != "" {
+				props = append(props, Prop{Row: i + 1, Col: col, Attr: v.Attr})
+			}
 			col += v.Attr.Length
 		}
 		lines = append(lines, props)
@@ -194,48 +259,178 @@ func (c *Colorizer) Render() [][]Prop {
 	return lines
 }
 
-func doTextObj(parser *sitter.Parser, lname string, code string, column uint32, row uint32) {
+var (
+	errUnknownLanguage = errors.New("unknown language")
+	errCanceled        = errors.New("canceled")
+)
+
+// parseTimeout bounds the time spent parsing and highlighting a single
+// request.
+var parseTimeout time.Duration
+
+var (
+	languageCacheMu sync.Mutex
+	languageCache   = map[string]*sitter.Language{}
+)
+
+// getLanguage returns the language object of lname, creating it on first
+// use.
+func getLanguage(lname string) (*sitter.Language, error) {
+	languageCacheMu.Lock()
+	defer languageCacheMu.Unlock()
+	if lang, ok := languageCache[lname]; ok {
+		return lang, nil
+	}
 	f, ok := languages[lname]
 	if !ok {
-		return
+		return nil, fmt.Errorf("%w: %s", errUnknownLanguage, lname)
 	}
 	lang := f()
-	parser.Reset()
-	parser.SetLanguage(lang)
-	root := parser.Parse(nil, []byte(code)).RootNode()
+	languageCache[lname] = lang
+	return lang, nil
+}
+
+// parsers holds a parser per language so that the language is set only
+// once. A parsers value must not be used by more than one goroutine.
+type parsers map[string]*sitter.Parser
+
+func (ps parsers) get(lname string) (*sitter.Parser, *sitter.Language, error) {
+	lang, err := getLanguage(lname)
+	if err != nil {
+		return nil, nil, err
+	}
+	parser, ok := ps[lname]
+	if !ok {
+		parser = sitter.NewParser()
+		parser.SetLanguage(lang)
+		ps[lname] = parser
+	}
+	return parser, lang, nil
+}
+
+// reset discards the state of interrupted parses.
+func (ps parsers) reset() {
+	for _, parser := range ps {
+		parser.Reset()
+	}
+}
+
+// withTimeout bounds ctx by parseTimeout.
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if parseTimeout > 0 {
+		return context.WithTimeout(ctx, parseTimeout)
+	}
+	return ctx, func() {}
+}
+
+// contextError returns why ctx is done, or nil if it isn't.
+func contextError(ctx context.Context, what string) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return errCanceled
+	case context.DeadlineExceeded:
+		return fmt.Errorf("%s timed out after %v", what, parseTimeout)
+	}
+	return nil
+}
+
+// parseChunk is the size of the pieces of code handed to the parser. The
+// context is checked before each of them.
+const parseChunk = 4096
+
+// parse parses code until ctx is done or parseTimeout expires. The parser
+// is not given ctx: its cancellation flag may be raised after a parse has
+// already finished, and then fails the next parse. Instead the input ends
+// early once ctx is done, and the timeout is the parser's operation limit.
+func parse(ctx context.Context, parser *sitter.Parser, code []byte) (*sitter.Tree, error) {
+	parser.SetOperationLimit(int(parseTimeout.Microseconds()))
+	tree, err := parser.ParseInputCtx(context.Background(), nil, sitter.Input{
+		Read: func(offset uint32, _ sitter.Point) []byte {
+			if int(offset) >= len(code) || ctx.Err() != nil {
+				return nil
+			}
+			end := int(offset) + parseChunk
+			if end > len(code) {
+				end = len(code)
+			}
+			return code[offset:end]
+		},
+		Encoding: sitter.InputEncodingUTF8,
+	})
+	if err == nil && tree == nil {
+		err = errors.New("parse failed")
+	}
+	if err == nil {
+		err = contextError(ctx, "parse")
+	}
+	if err != nil {
+		// A halted parser resumes the old parse unless it is reset.
+		parser.Reset()
+		if errors.Is(err, sitter.ErrOperationLimit) {
+			return nil, fmt.Errorf("parse timed out after %v", parseTimeout)
+		}
+		return nil, err
+	}
+	return tree, nil
+}
+
+func doTextObj(ctx context.Context, ps parsers, lname string, code string, column uint32, row uint32) (*Node, error) {
+	parser, _, err := ps.get(lname)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := parse(ctx, parser, []byte(code))
+	if err != nil {
+		return nil, err
+	}
+	root := tree.RootNode()
 	pt := sitter.Point{
 		Row:    row,
 		Column: column,
 	}
 	node := root.NamedDescendantForPointRange(pt, pt)
 	if node == nil {
-		json.NewEncoder(os.Stdout).Encode(Response{"textobj", "not found"})
-	} else {
-		json.NewEncoder(os.Stdout).Encode(Response{"textobj", &Node{
-			Type: node.Type(),
-			Start: Point{
-				Row:    node.StartPoint().Row,
-				Column: node.StartPoint().Column,
-			},
-			End: Point{
-				Row:    node.EndPoint().Row,
-				Column: node.EndPoint().Column,
-			},
-		}})
+		return nil, nil
 	}
+	return &Node{
+		Type: node.Type(),
+		Start: Point{
+			Row:    node.StartPoint().Row,
+			Column: node.StartPoint().Column,
+		},
+		End: Point{
+			Row:    node.EndPoint().Row,
+			Column: node.EndPoint().Column,
+		},
+	}, nil
 }
 
-func doSyntax(parser *sitter.Parser, lname string, code string) {
-	f, ok := languages[lname]
-	if !ok {
-		return
+func doSyntax(ctx context.Context, ps parsers, lname string, code string) ([][]Prop, error) {
+	parser, lang, err := ps.get(lname)
+	if err != nil {
+		return nil, err
 	}
-	lang := f()
-	parser.Reset()
-	parser.SetLanguage(lang)
-	root := parser.Parse(nil, []byte(code)).RootNode()
+	tree, err := parse(ctx, parser, []byte(code))
+	if err != nil {
+		return nil, err
+	}
+	return highlightTree(ctx, lname, lang, tree.RootNode(), []byte(code))
+}
 
-	colorizer := NewColorizer(int(root.StartPoint().Row), int(root.StartPoint().Column))
+// highlightTree returns the props of the highlighted nodes of root. Running
+// the queries stops when ctx is done or parseTimeout expires.
+func highlightTree(ctx context.Context, lname string, lang *sitter.Language, root *sitter.Node, code []byte) ([][]Prop, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	h := getHighlighter(lname, lang)
+	captures, err := h.captures(ctx, root, code)
+	if err != nil {
+		return nil, err
+	}
+
+	// The root node starts after leading white space, but the rows of the
+	// rendered props are counted from the top of the buffer.
+	colorizer := NewColorizer(0, 0)
 	types := []string{}
 	var process_node func(node *sitter.Node)
 	process_node = func(node *sitter.Node) {
@@ -245,15 +440,21 @@ func doSyntax(parser *sitter.Parser, lname string, code string) {
 		}
 		types = append(types, nt)
 		color := ""
-		if lang.SymbolType(node.Symbol()) == sitter.SymbolTypeAnonymous {
-			if v, ok := keywords[lname][nt]; ok {
-				color = v
-			}
-		} else {
-			if v, ok := symbols[lname][nt]; ok {
-				color = v
+		if h.builtin {
+			if lang.SymbolType(node.Symbol()) == sitter.SymbolTypeAnonymous {
+				if v, ok := keywords[lname][nt]; ok {
+					color = v
+				}
+			} else {
+				if v, ok := symbols[lname][nt]; ok {
+					color = v
+				}
 			}
 		}
+		builtin := capture{group: color, priority: defaultPriority, order: -1}
+		if c, ok := captures[keyOf(node)]; ok && (color == "" || c.beats(builtin)) {
+			color = c.group
+		}
 
 		if color != "" {
 			colorizer.Start(color, int(node.StartPoint().Row), int(node.StartPoint().Column))
@@ -265,32 +466,32 @@ func doSyntax(parser *sitter.Parser, lname string, code string) {
 
 		types = append(types, "/"+nt)
 
-		if color != "" {
-			colorizer.End(int(node.EndPoint().Row), int(node.EndPoint().Column))
+		if color != "" && err == nil {
+			err = colorizer.End(int(node.EndPoint().Row), int(node.EndPoint().Column))
 		}
 	}
 	process_node(root)
-	json.NewEncoder(os.Stdout).Encode(Response{"syntax", colorizer.Render()})
-}
-
-func readLine(reader *bufio.Reader, buf *bytes.Buffer) error {
-	for {
-		b, prefix, err := reader.ReadLine()
-		if err != nil {
-			return err
-		}
-		buf.Write(b)
-		if !prefix {
-			break
-		}
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return colorizer.Render(), nil
 }
 
 func main() {
 	var showVersion bool
+	var queries string
+	var grammars string
+	var addr string
+	var lsp bool
 	flag.BoolVar(&debug, "debug", false, "debug")
 	flag.BoolVar(&showVersion, "V", false, "Print the version")
+	flag.StringVar(&queries, "queries", defaultQueryPath(), "Directories to load <lang>/highlights.scm from")
+	flag.StringVar(&grammars, "grammars", defaultGrammarPath(), "Directories to load <lang>.so grammars and their queries/ from")
+	flag.DurationVar(&parseTimeout, "timeout", 5*time.Second, "Give up parsing or highlighting a request after this time (0 for no limit)")
+	flag.IntVar(&workers, "workers", workers, "Number of requests handled in parallel")
+	flag.StringVar(&format, "format", format, "Encoding of requests and responses: json, compact or msgpack")
+	flag.BoolVar(&lsp, "lsp", false, "Speak the Language Server Protocol on stdin/stdout")
+	flag.StringVar(&addr, "listen", "", "Serve clients on a Unix socket (unix:/path) or TCP address ([host]:port, loopback without a host) instead of stdin")
 	flag.Parse()
 
 	if showVersion {
@@ -298,29 +499,51 @@ func main() {
 		return
 	}
 
-	parser := sitter.NewParser()
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		var buf bytes.Buffer
-		err := readLine(reader, &buf)
-		if err != nil {
-			break
-		}
-		var input []string
-		err = json.Unmarshal(buf.Bytes(), &input)
-		if err != nil {
-			continue
+	grammarPath = filepath.SplitList(grammars)
+	queryPath = append(loadGrammars(), filepath.SplitList(queries)...)
+
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "lint-queries":
+			os.Exit(lintQueries(flag.Args()[1:]))
+		case "detect":
+			os.Exit(detect(flag.Args()[1:]))
+		case "build-grammar":
+			os.Exit(buildGrammarCommand(flag.Args()[1:]))
+		case "highlight":
+			os.Exit(highlightCommand(flag.Args()[1:]))
+		case "html":
+			os.Exit(htmlCommand(flag.Args()[1:]))
+		case "parse":
+			os.Exit(parseCommand(flag.Args()[1:]))
+		case "query":
+			os.Exit(queryCommand(flag.Args()[1:]))
+		case "check":
+			os.Exit(checkCommand(flag.Args()[1:]))
+		case "grep":
+			os.Exit(grepCommand(flag.Args()[1:]))
+		case "rewrite":
+			os.Exit(rewriteCommand(flag.Args()[1:]))
+		default:
+			fmt.Fprintf(os.Stderr, "%s: unknown command %q\n", name, flag.Arg(0))
+			os.Exit(2)
 		}
-		if input[0] == "version" {
-			json.NewEncoder(os.Stdout).Encode(Response{"version", version})
-		} else if input[0] == "syntax" && len(input) == 3 {
-			doSyntax(parser, input[1], input[2])
-		} else if input[0] == "textobj" /*&& len(input) == 5*/ {
-			col, _ := strconv.Atoi(input[3])
-			line, _ := strconv.Atoi(input[4])
-			doTextObj(parser, input[1], input[2], uint32(col), uint32(line))
-		} else {
-			json.NewEncoder(os.Stdout).Encode(Response{"error", "invalid command"})
+	}
+
+	c, err := newCodec(format, os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(2)
+	}
+	if lsp {
+		os.Exit(serveLSP(os.Stdin, os.Stdout))
+	}
+	if addr != "" {
+		if err := listen(addr); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			os.Exit(1)
 		}
+		return
 	}
+	newSession(c).serve()
 }
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+var errMsgpack = errors.New("msgpack: invalid data")
+
+// maxMsgpackLen bounds the length of strings, arrays and maps so that a
+// broken message can't make us allocate everything.
+const maxMsgpackLen = 1 << 28
+
+const maxMsgpackDepth = 64
+
+func encodeMsgpack(w *bufio.Writer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		w.WriteByte(0xc0)
+	case bool:
+		if v {
+			w.WriteByte(0xc3)
+		} else {
+			w.WriteByte(0xc2)
+		}
+	case int:
+		encodeMsgpackInt(w, int64(v))
+	case int64:
+		encodeMsgpackInt(w, v)
+	case uint32:
+		encodeMsgpackInt(w, int64(v))
+	case string:
+		encodeMsgpackLen(w, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		w.WriteString(v)
+	case []interface{}:
+		encodeMsgpackLen(w, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, e := range v {
+			if err := encodeMsgpack(w, e); err != nil {
+				return err
+			}
+		}
+	case []int:
+		encodeMsgpackLen(w, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, e := range v {
+			encodeMsgpackInt(w, int64(e))
+		}
+	case []string:
+		encodeMsgpackLen(w, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, e := range v {
+			encodeMsgpack(w, e)
+		}
+	case [][]int:
+		encodeMsgpackLen(w, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, e := range v {
+			encodeMsgpack(w, e)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		encodeMsgpackLen(w, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		for _, k := range keys {
+			encodeMsgpack(w, k)
+			if err := encodeMsgpack(w, v[k]); err != nil {
+				return err
+			}
+		}
+	case *compactProps:
+		return encodeMsgpack(w, map[string]interface{}{"types": v.Types, "lines": v.Lines})
+	case Point:
+		return encodeMsgpack(w, map[string]interface{}{"row": v.Row, "column": v.Column})
+	case *Node:
+		return encodeMsgpack(w, map[string]interface{}{"type": v.Type, "start": v.Start, "end": v.End})
+	case []Edit:
+		encodeMsgpackLen(w, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, e := range v {
+			encodeMsgpack(w, map[string]interface{}{"start": e.Start, "end": e.End, "text": e.Text})
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot encode %T", v)
+	}
+	return nil
+}
+
+func encodeMsgpackInt(w *bufio.Writer, n int64) {
+	var b [9]byte
+	switch {
+	case n >= 0 && n <= 0x7f:
+		w.WriteByte(byte(n))
+	case n < 0 && n >= -32:
+		w.WriteByte(byte(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		b[0] = 0xd2
+		binary.BigEndian.PutUint32(b[1:], uint32(n))
+		w.Write(b[:5])
+	default:
+		b[0] = 0xd3
+		binary.BigEndian.PutUint64(b[1:], uint64(n))
+		w.Write(b[:9])
+	}
+}
+
+// encodeMsgpackLen writes the header of a string, array or map of n
+// elements: fix|n if n <= max, else the 8, 16 or 32 bit form. Arrays and
+// maps have no 8 bit form.
+func encodeMsgpackLen(w *bufio.Writer, n int, fix byte, max int, b8, b16, b32 byte) {
+	var b [5]byte
+	switch {
+	case n <= max:
+		w.WriteByte(fix | byte(n))
+	case b8 != 0 && n <= math.MaxUint8:
+		w.Write([]byte{b8, byte(n)})
+	case n <= math.MaxUint16:
+		b[0] = b16
+		binary.BigEndian.PutUint16(b[1:], uint16(n))
+		w.Write(b[:3])
+	default:
+		b[0] = b32
+		binary.BigEndian.PutUint32(b[1:], uint32(n))
+		w.Write(b[:5])
+	}
+}
+
+// decodeMsgpack reads a value. Integers are returned as int64, strings and
+// binaries as string, arrays as []interface{} and maps as
+// map[string]interface{} with the keys formatted by fmt.
+func decodeMsgpack(r *bufio.Reader, depth int) (interface{}, error) {
+	if depth > maxMsgpackDepth {
+		return nil, fmt.Errorf("%w: nested too deeply", errMsgpack)
+	}
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return decodeMsgpackString(r, int(c&0x1f))
+	case c&0xf0 == 0x90:
+		return decodeMsgpackArray(r, int(c&0x0f), depth)
+	case c&0xf0 == 0x80:
+		return decodeMsgpackMap(r, int(c&0x0f), depth)
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := readUint(r, 1<<(c-0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: integer overflow", errMsgpack)
+		}
+		return int64(n), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, err := readUint(r, size)
+		if err != nil {
+			return nil, err
+		}
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, nil
+	case 0xca:
+		n, err := readUint(r, 4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := readUint(r, 8)
+		return math.Float64frombits(n), err
+	case 0xc4, 0xd9:
+		n, err := readUint(r, 1)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgpackString(r, int(n))
+	case 0xc5, 0xda:
+		n, err := readUint(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgpackString(r, int(n))
+	case 0xc6, 0xdb:
+		n, err := readUint(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgpackString(r, int(n))
+	case 0xdc, 0xdd:
+		n, err := readUint(r, 2<<(c-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgpackArray(r, int(n), depth)
+	case 0xde, 0xdf:
+		n, err := readUint(r, 2<<(c-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgpackMap(r, int(n), depth)
+	}
+	return nil, fmt.Errorf("%w: unsupported type 0x%02x", errMsgpack, c)
+}
+
+func readUint(r *bufio.Reader, size int) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[8-size:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func decodeMsgpackString(r *bufio.Reader, n int) (interface{}, error) {
+	if n > maxMsgpackLen {
+		return nil, fmt.Errorf("%w: string too long", errMsgpack)
+	}
+	// Grow with the data read rather than trusting the length.
+	var b strings.Builder
+	if _, err := io.CopyN(&b, r, int64(n)); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return b.String(), nil
+}
+
+func decodeMsgpackArray(r *bufio.Reader, n int, depth int) (interface{}, error) {
+	if n > maxMsgpackLen {
+		return nil, fmt.Errorf("%w: array too long", errMsgpack)
+	}
+	a := []interface{}{}
+	for i := 0; i < n; i++ {
+		v, err := decodeMsgpack(r, depth+1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func decodeMsgpackMap(r *bufio.Reader, n int, depth int) (interface{}, error) {
+	if n > maxMsgpackLen {
+		return nil, fmt.Errorf("%w: map too long", errMsgpack)
+	}
+	m := map[string]interface{}{}
+	for i := 0; i < n; i++ {
+		k, err := decodeMsgpack(r, depth+1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		v, err := decodeMsgpack(r, depth+1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		m[fmt.Sprint(k)] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mattn/vim-treesitter/internal/query"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// queryPath is the list of directories searched for
+// <lang>/highlights.scm overrides.
+var queryPath []string
+
+// bundledQueries are the queries of the languages without builtin
+// highlighting. They come before the files on the query path.
+//
+//go:embed queries
+var bundledQueries embed.FS
+
+// readQueryFiles returns fname of the language, the bundled file first and
+// then the files on the query path in order.
+func readQueryFiles(lname, fname string) []*querySource {
+	var files []*querySource
+	bundled := path.Join("queries", lname, fname)
+	if b, err := bundledQueries.ReadFile(bundled); err == nil {
+		files = append(files, &querySource{path: bundled, src: b})
+	}
+	for _, dir := range queryPath {
+		file := filepath.Join(dir, lname, fname)
+		if b, err := os.ReadFile(file); err == nil {
+			files = append(files, &querySource{path: file, src: b})
+		}
+	}
+	return files
+}
+
+func defaultQueryPath() string {
+	if dir := configDir(); dir != "" {
+		return filepath.Join(dir, "queries")
+	}
+	return ""
+}
+
+type predicate struct {
+	name string
+	args []*query.Arg
+	re   *regexp.Regexp
+}
+
+type highlightQuery struct {
+	q          *sitter.Query
+	groups     []string
+	predicates [][]*predicate
+	priorities []int
+}
+
+// highlighter holds the runtime queries of a language. When builtin is set
+// the generated symbols and keywords maps are applied before the queries.
+type highlighter struct {
+	builtin bool
+	queries []*highlightQuery
+}
+
+var (
+	highlightersMu sync.Mutex
+	highlighters   = map[string]*highlighter{}
+)
+
+func reloadQueries() {
+	highlightersMu.Lock()
+	defer highlightersMu.Unlock()
+	highlighters = map[string]*highlighter{}
+}
+
+func getHighlighter(lname string, lang *sitter.Language) *highlighter {
+	highlightersMu.Lock()
+	defer highlightersMu.Unlock()
+	if h, ok := highlighters[lname]; ok {
+		return h
+	}
+	sources, extends := querySources(lname, "highlights.scm", map[string]bool{})
+	h := &highlighter{builtin: extends}
+	for _, src := range sources {
+		if hq := compileHighlightQuery(src, lang); hq != nil {
+			h.queries = append(h.queries, hq)
+		}
+	}
+	highlighters[lname] = h
+	return h
+}
+
+type querySource struct {
+	path string
+	q    *query.Query
+	src  []byte
+}
+
+// querySources returns the query files for the language in the order they
+// apply, and whether they extend the builtin highlighting instead of
+// replacing it. A file without "; extends" replaces everything before it,
+// and the files of inherited languages come before the file inheriting them.
+// Files which don't parse are reported and skipped.
+func querySources(lname, fname string, visiting map[string]bool) ([]*querySource, bool) {
+	if visiting[lname] {
+		return nil, true
+	}
+	visiting[lname] = true
+	defer delete(visiting, lname)
+
+	var sources []*querySource
+	extends := true
+	for _, src := range readQueryFiles(lname, fname) {
+		q, err := query.Parse(src.src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s:%v\n", name, src.path, err)
+			continue
+		}
+		src.q = q
+		if !q.Extends {
+			sources = nil
+			extends = false
+		}
+		for _, l := range q.Inherits {
+			inherited, _ := querySources(l, fname, visiting)
+			sources = append(sources, inherited...)
+		}
+		sources = append(sources, src)
+	}
+	return sources, extends
+}
+
+// compileHighlightQuery compiles the patterns of src. Patterns which the
+// grammar rejects are reported and dropped so that one bad pattern doesn't
+// disable the whole file.
+func compileHighlightQuery(src *querySource, lang *sitter.Language) *highlightQuery {
+	patterns := src.q.Patterns
+	for len(patterns) > 0 {
+		var b []byte
+		offsets := make([]int, len(patterns))
+		for i, p := range patterns {
+			offsets[i] = len(b)
+			b = append(b, src.src[p.Pos.Offset:p.End.Offset]...)
+			b = append(b, '\n')
+		}
+		q, err := sitter.NewQuery(b, lang)
+		if err == nil {
+			return newHighlightQuery(q, patterns)
+		}
+		var qe *sitter.QueryError
+		if !errors.As(err, &qe) {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", name, src.path, err)
+			return nil
+		}
+		i := len(offsets) - 1
+		for i > 0 && offsets[i] > int(qe.Offset) {
+			i--
+		}
+		pos := offsetPos(src.src, patterns[i].Pos.Offset+int(qe.Offset)-offsets[i])
+		msg := qe.Message
+		if j := strings.Index(msg, " at line "); j >= 0 {
+			msg = msg[:j]
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s:%v: %s, pattern dropped\n", name, src.path, pos, msg)
+		patterns = append(patterns[:i:i], patterns[i+1:]...)
+	}
+	return nil
+}
+
+func newHighlightQuery(q *sitter.Query, patterns []*query.Node) *highlightQuery {
+	hq := &highlightQuery{q: q}
+	for i := uint32(0); i < q.CaptureCount(); i++ {
+		name := q.CaptureNameForId(i)
+		g := query.HighlightGroup(name)
+		if strings.HasPrefix(name, "_") || !has(highlightGroups(), g) {
+			g = ""
+		}
+		hq.groups = append(hq.groups, g)
+	}
+	for _, p := range patterns {
+		var preds []*predicate
+		priority := defaultPriority
+		for _, qp := range p.AllPredicates() {
+			pred := &predicate{name: qp.Name, args: qp.Args}
+			switch qp.Name {
+			case "set!":
+				if len(qp.Args) == 2 && qp.Args[0].Value == "priority" {
+					if n, err := strconv.Atoi(qp.Args[1].Value); err == nil {
+						priority = n
+					}
+				}
+			case "match?", "not-match?", "vim-match?", "not-vim-match?", "lua-match?", "not-lua-match?":
+				if len(qp.Args) == 2 {
+					re := qp.Args[1].Value
+					if strings.HasSuffix(qp.Name, "lua-match?") {
+						re = luaPattern(re)
+					}
+					pred.re, _ = regexp.Compile(re)
+				}
+			}
+			preds = append(preds, pred)
+		}
+		hq.predicates = append(hq.predicates, preds)
+		hq.priorities = append(hq.priorities, priority)
+	}
+	return hq
+}
+
+// luaClasses are the ASCII classes of the Lua character classes. The upper
+// case letter of a class is its complement.
+var luaClasses = map[byte]string{
+	'a': "alpha",
+	'c': "cntrl",
+	'd': "digit",
+	'g': "graph",
+	'l': "lower",
+	'p': "punct",
+	's': "space",
+	'u': "upper",
+	'w': "alnum",
+	'x': "xdigit",
+}
+
+// luaPattern converts a Lua pattern to a regular expression. Only the
+// character classes and the quantifiers are handled, not %b, %f or
+// captures.
+func luaPattern(s string) string {
+	var b strings.Builder
+	class := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+1 < len(s) {
+			i++
+			c := s[i]
+			if name, ok := luaClasses[c|0x20]; ok {
+				if c < 'a' {
+					name = "^" + name
+				}
+				if class {
+					b.WriteString("[:" + name + ":]")
+				} else {
+					b.WriteString("[[:" + name + ":]]")
+				}
+			} else if c == '-' || c == '^' {
+				b.WriteString(`\` + string(c))
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+			continue
+		}
+		switch {
+		case s[i] == '[':
+			class = true
+		case s[i] == ']':
+			class = false
+		case s[i] == '-' && !class && i > 0:
+			b.WriteString("*?")
+			continue
+		case s[i] == '|' || s[i] == '\\' || s[i] == '{' || s[i] == '}':
+			// Literal in Lua patterns, but not in regular expressions.
+			b.WriteString(regexp.QuoteMeta(string(s[i])))
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func (hq *highlightQuery) captureNode(m *sitter.QueryMatch, name string) *sitter.Node {
+	for _, c := range m.Captures {
+		if hq.q.CaptureNameForId(c.Index) == name {
+			return c.Node
+		}
+	}
+	return nil
+}
+
+// accept evaluates the predicates of the pattern which produced m.
+func (hq *highlightQuery) accept(m *sitter.QueryMatch, code []byte) bool {
+	if int(m.PatternIndex) >= len(hq.predicates) {
+		return true
+	}
+	text := func(a *query.Arg) (string, bool) {
+		if !a.Capture {
+			return a.Value, true
+		}
+		n := hq.captureNode(m, a.Value)
+		if n == nil {
+			return "", false
+		}
+		return n.Content(code), true
+	}
+	for _, p := range hq.predicates[m.PatternIndex] {
+		if len(p.args) == 0 {
+			continue
+		}
+		s, ok := text(p.args[0])
+		if !ok {
+			continue
+		}
+		negate := strings.HasPrefix(p.name, "not-")
+		var result bool
+		switch strings.TrimPrefix(p.name, "not-") {
+		case "eq?":
+			if len(p.args) != 2 {
+				continue
+			}
+			t, ok := text(p.args[1])
+			if !ok {
+				continue
+			}
+			result = s == t
+		case "match?", "vim-match?", "lua-match?":
+			if p.re == nil {
+				continue
+			}
+			result = p.re.MatchString(s)
+		case "any-of?":
+			for _, a := range p.args[1:] {
+				if s == a.Value {
+					result = true
+					break
+				}
+			}
+		case "contains?":
+			for _, a := range p.args[1:] {
+				if strings.Contains(s, a.Value) {
+					result = true
+					break
+				}
+			}
+		default:
+			continue
+		}
+		if result == negate {
+			return false
+		}
+	}
+	return true
+}
+
+type nodeKey struct {
+	start, end uint32
+	symbol     sitter.Symbol
+}
+
+func keyOf(n *sitter.Node) nodeKey {
+	return nodeKey{n.StartByte(), n.EndByte(), n.Symbol()}
+}
+
+// defaultPriority is the priority of captures without a
+// (#set! "priority" N) directive, as in Neovim.
+const defaultPriority = 100
+
+// capture is the highlight chosen for a node. order is the position of the
+// pattern among all patterns of the language, builtin colors come first.
+type capture struct {
+	group    string
+	priority int
+	order    int
+}
+
+// beats reports whether c takes precedence over o for the same node: the
+// higher priority wins, and on a tie the later pattern.
+func (c capture) beats(o capture) bool {
+	if c.priority != o.priority {
+		return c.priority > o.priority
+	}
+	return c.order >= o.order
+}
+
+// captures runs the queries over root and returns the winning capture for
+// each captured node. It gives up between two matches once ctx is done.
+func (h *highlighter) captures(ctx context.Context, root *sitter.Node, code []byte) (map[nodeKey]capture, error) {
+	colors := map[nodeKey]capture{}
+	base := 0
+	for _, hq := range h.queries {
+		qc := sitter.NewQueryCursor()
+		qc.Exec(hq.q, root)
+		for {
+			if err := contextError(ctx, "highlighting"); err != nil {
+				return nil, err
+			}
+			m, ok := qc.NextMatch()
+			if !ok {
+				break
+			}
+			if !hq.accept(m, code) {
+				continue
+			}
+			c := capture{
+				priority: hq.priorities[m.PatternIndex],
+				order:    base + int(m.PatternIndex),
+			}
+			for _, mc := range m.Captures {
+				if c.group = hq.groups[mc.Index]; c.group == "" {
+					continue
+				}
+				k := keyOf(mc.Node)
+				if cur, ok := colors[k]; !ok || c.beats(cur) {
+					colors[k] = c
+				}
+			}
+		}
+		base += len(hq.priorities)
+	}
+	return colors, nil
+}
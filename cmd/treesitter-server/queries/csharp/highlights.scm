@@ -0,0 +1,176 @@
+(method_declaration
+  name: (identifier) @method)
+
+(local_function_statement
+  name: (identifier) @method)
+
+(invocation_expression
+  function: (identifier) @method)
+
+(invocation_expression
+  function: (member_access_expression
+    name: (identifier) @method))
+
+(member_access_expression
+  name: (identifier) @property)
+
+(property_declaration
+  name: (identifier) @property)
+
+(parameter
+  name: (identifier) @parameter)
+
+(class_declaration
+  name: (identifier) @type)
+
+(interface_declaration
+  name: (identifier) @type)
+
+(struct_declaration
+  name: (identifier) @type)
+
+(enum_declaration
+  name: (identifier) @type)
+
+(record_declaration
+  name: (identifier) @type)
+
+(constructor_declaration
+  name: (identifier) @constructor)
+
+(object_creation_expression
+  type: (identifier) @type)
+
+(enum_member_declaration
+  (identifier) @constant)
+
+(predefined_type) @type.builtin
+(generic_name
+  (identifier) @type)
+
+(namespace_declaration
+  name: [
+    (identifier)
+    (qualified_name)
+  ] @namespace)
+
+(using_directive
+  [
+    (identifier)
+    (qualified_name)
+  ] @namespace)
+
+(attribute
+  name: (identifier) @attribute)
+
+(comment) @comment
+
+[
+  (string_literal)
+  (verbatim_string_literal)
+  (interpolated_string_expression)
+] @string
+
+(escape_sequence) @string.escape
+(character_literal) @character
+(integer_literal) @number
+(real_literal) @float
+(boolean_literal) @boolean
+(null_literal) @constant.builtin
+
+"this" @variable.builtin
+"base" @variable.builtin
+
+(modifier) @keyword
+
+[
+  "class"
+  "interface"
+  "struct"
+  "enum"
+  "new"
+  "var"
+  "get"
+  "set"
+  "in"
+  "out"
+  "ref"
+  "is"
+  "as"
+  "typeof"
+  "sizeof"
+  "event"
+  "delegate"
+  "operator"
+  "where"
+  "lock"
+  "using"
+] @keyword
+
+"record" @keyword
+
+"namespace" @include
+"return" @keyword.return
+
+[
+  "if"
+  "else"
+  "switch"
+  "case"
+  "default"
+] @conditional
+
+[
+  "for"
+  "foreach"
+  "while"
+  "do"
+  "break"
+  "continue"
+] @repeat
+
+[
+  "try"
+  "catch"
+  "finally"
+  "throw"
+] @exception
+
+[
+  "("
+  ")"
+  "["
+  "]"
+  "{"
+  "}"
+] @punctuation.bracket
+
+[
+  ";"
+  ","
+  "."
+  ":"
+] @punctuation.delimiter
+
+[
+  "="
+  "=="
+  "!="
+  "<="
+  ">="
+  "&&"
+  "||"
+  "!"
+  "+"
+  "-"
+  "*"
+  "/"
+  "%"
+  "++"
+  "--"
+  "+="
+  "-="
+  "=>"
+  "??"
+  "?"
+] @operator
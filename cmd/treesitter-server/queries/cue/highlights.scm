@@ -0,0 +1,48 @@
+[
+  "package"
+  "import"
+] @include
+
+[
+  "let"
+  "for"
+  "in"
+  "if"
+] @keyword
+
+(package_clause
+  (package_identifier) @namespace)
+
+(import_spec
+  path: (string) @namespace)
+
+(field
+  (label
+    (identifier) @field))
+
+(attribute) @attribute
+
+(string) @string
+(escape_char) @string.escape
+(number) @number
+(float) @float
+(boolean) @boolean
+(null) @constant.builtin
+(builtin_function) @function
+(primitive_type) @type
+
+(comment) @comment
+
+[
+  "("
+  ")"
+  "["
+  "]"
+  "{"
+  "}"
+] @punctuation.bracket
+
+[
+  ","
+  ":"
+] @punctuation.delimiter
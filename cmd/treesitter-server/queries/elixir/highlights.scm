@@ -0,0 +1,60 @@
+[
+  "do"
+  "end"
+  "fn"
+  "after"
+  "else"
+  "rescue"
+  "catch"
+] @keyword
+
+[
+  "and"
+  "or"
+  "not"
+  "in"
+  "when"
+] @keyword.operator
+
+(call
+  target: (identifier) @keyword
+  (#any-of? @keyword "def" "defp" "defmodule" "defmacro" "defmacrop" "defstruct" "defprotocol" "defimpl" "defdelegate" "defguard"))
+
+(call
+  target: (identifier) @include
+  (#any-of? @include "alias" "import" "require" "use"))
+
+(call
+  target: (identifier) @function)
+
+(alias) @type
+(atom) @symbol
+(quoted_atom) @symbol
+(keyword) @symbol
+
+(string) @string
+(charlist) @string
+(escape_sequence) @string.escape
+(sigil) @string.special
+(integer) @number
+(float) @float
+(boolean) @boolean
+(nil) @constant.builtin
+
+(comment) @comment
+
+[
+  "("
+  ")"
+  "["
+  "]"
+  "{"
+  "}"
+  "<<"
+  ">>"
+] @punctuation.bracket
+
+[
+  ","
+  "->"
+] @punctuation.delimiter
@@ -0,0 +1,102 @@
+[
+  (line_comment)
+  (block_comment)
+] @comment
+
+[
+  (module)
+  (exposing)
+  (port)
+  (effect)
+  (where)
+  (alias)
+  (type)
+  (as)
+  (infix)
+] @keyword
+
+(import) @include
+
+[
+  "if"
+  "then"
+  "else"
+  (case)
+  (of)
+] @conditional
+
+[
+  "let"
+  "in"
+] @keyword
+
+[
+  (operator_identifier)
+  (eq)
+  (colon)
+  (arrow)
+  (backslash)
+  "|"
+] @operator
+
+[
+  "("
+  ")"
+  "["
+  "]"
+  "{"
+  "}"
+] @punctuation.bracket
+
+[
+  ","
+  (dot)
+  (double_dot)
+] @punctuation.delimiter
+
+(function_declaration_left
+  .
+  (lower_case_identifier) @function)
+(type_annotation
+  .
+  (lower_case_identifier) @function)
+(port_annotation
+  .
+  (lower_case_identifier) @function)
+
+(upper_case_qid) @type
+(type_declaration
+  (upper_case_identifier) @type)
+(type_alias_declaration
+  (upper_case_identifier) @type)
+(union_variant
+  .
+  (upper_case_identifier) @constructor)
+(type_ref) @type
+(type_variable) @type
+
+(module_declaration
+  (upper_case_qid) @namespace)
+(import_clause
+  (upper_case_qid) @namespace)
+
+(field_type
+  .
+  (lower_case_identifier) @field)
+(field
+  .
+  (lower_case_identifier) @field)
+(field_access_expr
+  (lower_case_identifier) @field .)
+
+[
+  (open_quote)
+  (close_quote)
+  (regular_string_part)
+  (open_char)
+  (close_char)
+] @string
+(string_escape) @string.escape
+(invalid_string_escape) @danger
+
+(number_constant_expr) @number
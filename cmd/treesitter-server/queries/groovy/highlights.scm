@@ -0,0 +1,69 @@
+[
+  "def"
+  "class"
+  "interface"
+  "extends"
+  "new"
+  "in"
+  "!in"
+  "as"
+  "instanceof"
+  "!instanceof"
+  "assert"
+] @keyword
+
+[
+  "public"
+  "protected"
+  "private"
+  "static"
+  "final"
+  "synchronized"
+] @keyword
+
+"return" @keyword.return
+
+[
+  "if"
+  "else"
+  "switch"
+  "case"
+  "default"
+] @conditional
+
+[
+  "for"
+  "while"
+  "do"
+  (break)
+  (continue)
+] @repeat
+
+[
+  "try"
+  "catch"
+  "finally"
+] @exception
+
+[
+  "import"
+  "package"
+] @include
+
+(builtintype) @type.builtin
+(annotation) @attribute
+
+(function_definition
+  function: (identifier) @function)
+(function_call
+  function: (identifier) @function)
+
+(comment) @comment
+(groovy_doc) @comment
+(shebang) @comment
+(string) @string
+(escape_sequence) @string.escape
+(number_literal) @number
+(boolean_literal) @boolean
+(null) @constant.builtin
+"this" @variable.builtin
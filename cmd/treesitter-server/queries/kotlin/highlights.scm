@@ -0,0 +1,68 @@
+[
+  "class"
+  "interface"
+  "object"
+  "val"
+  "var"
+  "typealias"
+  "by"
+  "in"
+] @keyword
+
+"fun" @keyword.function
+"return" @keyword.return
+
+[
+  "if"
+  "else"
+  "when"
+] @conditional
+
+[
+  "for"
+  "while"
+  "do"
+] @repeat
+
+[
+  "try"
+  "catch"
+  "finally"
+  "throw"
+] @exception
+
+[
+  "package"
+  "import"
+] @include
+
+(package_header
+  (identifier) @namespace)
+
+(import_header
+  (identifier) @namespace)
+
+(class_declaration
+  (type_identifier) @type)
+
+(type_identifier) @type
+
+(function_declaration
+  (simple_identifier) @function)
+
+(call_expression
+  (simple_identifier) @function)
+
+(string_literal) @string
+(character_literal) @character
+(integer_literal) @number
+(real_literal) @float
+(boolean_literal) @boolean
+"null" @constant.builtin
+
+[
+  (line_comment)
+  (multiline_comment)
+] @comment
+
+(annotation) @attribute
@@ -0,0 +1,34 @@
+(atx_heading) @title
+(setext_heading) @title
+
+[
+  (atx_h1_marker)
+  (atx_h2_marker)
+  (atx_h3_marker)
+  (atx_h4_marker)
+  (atx_h5_marker)
+  (atx_h6_marker)
+  (setext_h1_underline)
+  (setext_h2_underline)
+] @punctuation.special
+
+(fenced_code_block_delimiter) @punctuation.delimiter
+(info_string) @label
+(code_fence_content) @literal
+(indented_code_block) @literal
+
+[
+  (list_marker_plus)
+  (list_marker_minus)
+  (list_marker_star)
+  (list_marker_dot)
+  (list_marker_parenthesis)
+] @punctuation.special
+
+(block_quote_marker) @punctuation.special
+(thematic_break) @punctuation.special
+
+(link_reference_definition
+  (link_label) @label)
+(link_destination) @string
+(link_title) @string
@@ -0,0 +1,65 @@
+[
+  "syntax"
+  "package"
+  "option"
+  "message"
+  "enum"
+  "service"
+  "rpc"
+  "returns"
+  "oneof"
+  "map"
+  "reserved"
+  "to"
+  "max"
+  "stream"
+  "weak"
+  "public"
+] @keyword
+
+"import" @include
+
+[
+  "repeated"
+  "optional"
+] @keyword
+
+(message_name) @type
+(enum_name) @type
+(service_name) @type
+(rpc_name) @method
+(type) @type
+(key_type) @type
+
+(full_ident) @namespace
+
+(string) @string
+(escape_sequence) @string.escape
+(int_lit) @number
+(float_lit) @float
+
+[
+  (true)
+  (false)
+] @boolean
+
+(comment) @comment
+
+[
+  "("
+  ")"
+  "["
+  "]"
+  "{"
+  "}"
+  "<"
+  ">"
+] @punctuation.bracket
+
+[
+  ";"
+  ","
+  "."
+] @punctuation.delimiter
+
+"=" @operator
@@ -0,0 +1,96 @@
+[
+  "class"
+  "object"
+  "trait"
+  "extends"
+  "with"
+  "val"
+  "var"
+  "type"
+  "new"
+  "case"
+] @keyword
+
+"def" @keyword.function
+"return" @keyword.return
+
+[
+  "if"
+  "else"
+  "match"
+] @conditional
+
+[
+  "for"
+  "while"
+  "do"
+  "yield"
+] @repeat
+
+[
+  "try"
+  "catch"
+  "finally"
+  "throw"
+] @exception
+
+[
+  "import"
+  "package"
+] @include
+
+[
+  "abstract"
+  "final"
+  "implicit"
+  "lazy"
+  "override"
+  "private"
+  "protected"
+  "sealed"
+] @keyword
+
+(class_definition
+  name: (identifier) @type)
+
+(object_definition
+  name: (identifier) @type)
+
+(trait_definition
+  name: (identifier) @type)
+
+(type_identifier) @type
+
+(function_definition
+  name: (identifier) @function)
+
+(call_expression
+  function: (identifier) @function)
+
+(parameter
+  name: (identifier) @parameter)
+
+(string) @string
+(interpolated_string_expression) @string
+(character_literal) @character
+(integer_literal) @number
+(floating_point_literal) @float
+(boolean_literal) @boolean
+(null_literal) @constant.builtin
+
+(comment) @comment
+
+[
+  "("
+  ")"
+  "["
+  "]"
+  "{"
+  "}"
+] @punctuation.bracket
+
+[
+  ","
+  "."
+  ":"
+] @punctuation.delimiter
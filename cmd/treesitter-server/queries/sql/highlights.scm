@@ -0,0 +1,70 @@
+(comment) @comment
+(marginalia) @comment
+
+(literal) @string
+
+(invocation
+  (object_reference
+    name: (identifier) @function))
+
+(relation
+  (object_reference
+    name: (identifier) @type))
+
+(field
+  name: (identifier) @field)
+
+[
+  (keyword_select)
+  (keyword_from)
+  (keyword_where)
+  (keyword_insert)
+  (keyword_into)
+  (keyword_values)
+  (keyword_update)
+  (keyword_set)
+  (keyword_delete)
+  (keyword_create)
+  (keyword_alter)
+  (keyword_drop)
+  (keyword_table)
+  (keyword_join)
+  (keyword_left)
+  (keyword_inner)
+  (keyword_on)
+  (keyword_as)
+  (keyword_order)
+  (keyword_group)
+  (keyword_by)
+  (keyword_limit)
+  (keyword_distinct)
+  (keyword_having)
+  (keyword_primary)
+  (keyword_key)
+] @keyword
+
+[
+  (keyword_and)
+  (keyword_or)
+  (keyword_not)
+  (keyword_in)
+  (keyword_is)
+] @keyword.operator
+
+(keyword_null) @constant.builtin
+
+[
+  (keyword_true)
+  (keyword_false)
+] @boolean
+
+[
+  "("
+  ")"
+] @punctuation.bracket
+
+[
+  ";"
+  ","
+  "."
+] @punctuation.delimiter
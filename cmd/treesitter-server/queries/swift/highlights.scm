@@ -0,0 +1,48 @@
+[
+  "let"
+  "var"
+  "class"
+  "struct"
+  "enum"
+  "protocol"
+  "extension"
+  "typealias"
+  "case"
+  "in"
+] @keyword
+
+"func" @keyword.function
+"return" @keyword.return
+"import" @include
+
+[
+  "if"
+  (else)
+  "guard"
+  "switch"
+] @conditional
+
+[
+  "for"
+  "while"
+  "repeat"
+] @repeat
+
+(type_identifier) @type
+
+(function_declaration
+  name: (simple_identifier) @function)
+
+(line_string_literal) @string
+(multi_line_string_literal) @string
+(integer_literal) @number
+(real_literal) @float
+(boolean_literal) @boolean
+"nil" @constant.builtin
+(self_expression) @variable.builtin
+(str_escaped_char) @string.escape
+
+[
+  (comment)
+  (multiline_comment)
+] @comment
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLuaPattern(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"^%u", "^[[:upper:]]"},
+		{"^[%w_]+$", "^[[:alnum:]_]+$"},
+		{"^[%a%d]", "^[[:alpha:][:digit:]]"},
+		{"[%p%s]", "[[:punct:][:space:]]"},
+		{"^%x+%.?", "^[[:xdigit:]]+\\.?"},
+		{"^[%%%]]", "^[%\\]]"},
+		{"^[%-%^a]", "^[\\-\\^a]"},
+		{"^a.-b", "^a.*?b"},
+		{"[a-z]", "[a-z]"},
+		// Complements.
+		{"^%S+$", "^[[:^space:]]+$"},
+		{"%W", "[[:^alnum:]]"},
+		{"^[%A%D]", "^[[:^alpha:][:^digit:]]"},
+		{"%U%L%P%X%C%G", "[[:^upper:]][[:^lower:]][[:^punct:]][[:^xdigit:]][[:^cntrl:]][[:^graph:]]"},
+		// Literals which are special in regular expressions.
+		{"a|b", "a\\|b"},
+		{"{x}\\", "\\{x\\}\\\\"},
+		{"[|{]", "[\\|\\{]"},
+	} {
+		got := luaPattern(tt.in)
+		if got != tt.want {
+			t.Errorf("luaPattern(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+		if _, err := regexp.Compile(got); err != nil {
+			t.Errorf("luaPattern(%q): %v", tt.in, err)
+		}
+	}
+	for _, tt := range []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"^%S+$", "ab", true},
+		{"^%S+$", "a b", false},
+		{"^[%W_]$", "_", true},
+		{"^[%W_]$", "a", false},
+		{"^[%W_]$", "-", true},
+		{"^a|b$", "a|b", true},
+		{"^a|b$", "a", false},
+	} {
+		if got := regexp.MustCompile(luaPattern(tt.pattern)).MatchString(tt.s); got != tt.match {
+			t.Errorf("%q matches %q: %v", tt.pattern, tt.s, got)
+		}
+	}
+}
+
+// withQueryPath sets the query path to dirs holding the given files for the
+// duration of the test.
+func withQueryPath(t *testing.T, files map[string]string) []string {
+	t.Helper()
+	root := t.TempDir()
+	var dirs []string
+	for name, src := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, d := range []string{"a", "b"} {
+		dirs = append(dirs, filepath.Join(root, d))
+	}
+	saved := queryPath
+	queryPath = dirs
+	reloadQueries()
+	t.Cleanup(func() {
+		queryPath = saved
+		reloadQueries()
+	})
+	return dirs
+}
+
+func TestQuerySources(t *testing.T) {
+	dirs := withQueryPath(t, map[string]string{
+		"a/one/highlights.scm":   "; extends\n(a) @keyword\n",
+		"b/one/highlights.scm":   "; extends\n(b) @keyword\n",
+		"a/two/highlights.scm":   "(a) @keyword\n",
+		"b/two/highlights.scm":   "(b) @keyword\n",
+		"a/three/highlights.scm": "; inherits: one,two\n; extends\n(c) @keyword\n",
+		"b/loop/highlights.scm":  "; inherits: loop\n(d) @keyword\n",
+		"a/elm/highlights.scm":   "; extends\n(e) @keyword\n",
+		"b/json/highlights.scm":  "(f) @keyword\n",
+		"a/bad/highlights.scm":   "(a) @keyword\n",
+		"b/bad/highlights.scm":   "(b\n",
+	})
+	a := func(l string) string { return filepath.Join(dirs[0], l, "highlights.scm") }
+	b := func(l string) string { return filepath.Join(dirs[1], l, "highlights.scm") }
+
+	stderr := os.Stderr
+	defer func() { os.Stderr = stderr }()
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+	os.Stderr = devnull
+
+	for _, tt := range []struct {
+		lname   string
+		want    []string
+		extends bool
+	}{
+		// Files on the query path apply in order and extend each other.
+		{"one", []string{a("one"), b("one")}, true},
+		// Without "; extends" the later file replaces the earlier one.
+		{"two", []string{b("two")}, false},
+		// Inherited languages come first, each resolved on its own.
+		{"three", []string{a("one"), b("one"), b("two"), a("three")}, true},
+		{"loop", []string{b("loop")}, false},
+		// The bundled query comes before the query path.
+		{"elm", []string{"queries/elm/highlights.scm", a("elm")}, false},
+		{"json", []string{b("json")}, false},
+		// A file which doesn't parse is skipped.
+		{"bad", []string{a("bad")}, false},
+		{"none", nil, true},
+	} {
+		sources, extends := querySources(tt.lname, "highlights.scm", map[string]bool{})
+		var got []string
+		for _, src := range sources {
+			got = append(got, src.path)
+		}
+		if !reflect.DeepEqual(got, tt.want) || extends != tt.extends {
+			t.Errorf("querySources(%q) = %q, %v; want %q, %v", tt.lname, got, extends, tt.want, tt.extends)
+		}
+	}
+}
+
+func TestHighlightPriority(t *testing.T) {
+	withQueryPath(t, map[string]string{
+		"a/go/highlights.scm": "; extends\n" +
+			"((identifier) @type (#eq? @type \"x\") (#set! \"priority\" 110))\n" +
+			"(identifier) @keyword\n" +
+			"(nothing) @string\n" +
+			"((identifier) @constant (#eq? @constant \"y\"))\n" +
+			"((identifier) @variable (#eq? @variable \"w\"))\n",
+	})
+	ps := parsers{}
+	parser, lang, err := ps.get("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := []byte("package p\n\nvar x, y, z, w int\n")
+	tree, err := parse(context.Background(), parser, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	h := getHighlighter("go", lang)
+	os.Stderr = stderr
+	w.Close()
+	report, _ := io.ReadAll(r)
+	if !h.builtin {
+		t.Error("a query with \"; extends\" replaces the builtin highlighting")
+	}
+	want := filepath.Join("go", "highlights.scm") + ":4:2: invalid node type 'nothing', pattern dropped\n"
+	if !strings.HasSuffix(string(report), want) {
+		t.Errorf("report of the dropped pattern %q; want suffix %q", report, want)
+	}
+
+	colors, err := h.captures(context.Background(), tree.RootNode(), code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for k, c := range colors {
+		got[string(code[k.start:k.end])] = c.group
+	}
+	for ident, want := range map[string]string{
+		"x": "TSType",     // the higher priority wins
+		"y": "TSConstant", // on a tie the later pattern
+		"z": "TSKeyword",
+		"w": "TSVariable", // a group of the builtin highlighting only
+	} {
+		if got[ident] != want {
+			t.Errorf("%s highlighted as %q; want %q", ident, got[ident], want)
+		}
+	}
+}
+
+func TestHighlightCanceled(t *testing.T) {
+	withQueryPath(t, map[string]string{"a/go/highlights.scm": "; extends\n(identifier) @type\n"})
+	ps := parsers{}
+	parser, lang, err := ps.get("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := []byte("package p\n\nvar x, y, z int\n")
+	tree, err := parse(context.Background(), parser, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tree.RootNode()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := highlightTree(ctx, "go", lang, root, code); err != errCanceled {
+		t.Errorf("highlighting with a canceled context: %v", err)
+	}
+
+	saved := parseTimeout
+	defer func() { parseTimeout = saved }()
+	parseTimeout = time.Nanosecond
+	_, err = highlightTree(context.Background(), "go", lang, root, code)
+	if err == nil || err.Error() != "highlighting timed out after 1ns" {
+		t.Errorf("highlighting past the timeout: %v", err)
+	}
+}
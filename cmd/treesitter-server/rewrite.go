@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Edit replaces the text between Start and End. Points are 0-based rows and
+// byte columns as in textobj responses.
+type Edit struct {
+	Start Point  `json:"start"`
+	End   Point  `json:"end"`
+	Text  string `json:"text"`
+
+	start, end uint32
+}
+
+// templatePart is literal text, or the text of a capture if capture is set.
+type templatePart struct {
+	text    string
+	capture bool
+}
+
+// parseTemplate splits a replacement into literal text and references to
+// captures, written $name or ${name}. $$ is a literal $.
+func parseTemplate(s string) ([]templatePart, error) {
+	var parts []templatePart
+	var lit strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			lit.WriteByte(s[i])
+			continue
+		}
+		var name string
+		switch c := s[i+1]; {
+		case c == '$':
+			lit.WriteByte('$')
+			i++
+			continue
+		case c == '{':
+			j := strings.IndexByte(s[i+2:], '}')
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated ${ at %d", i)
+			}
+			name = s[i+2 : i+2+j]
+			if name == "" {
+				return nil, fmt.Errorf("empty ${} at %d", i)
+			}
+			i += j + 2
+		case isCaptureChar(c):
+			j := i + 1
+			for j < len(s) && isCaptureChar(s[j]) {
+				j++
+			}
+			name = s[i+1 : j]
+			i = j - 1
+		default:
+			lit.WriteByte('$')
+			continue
+		}
+		if lit.Len() > 0 {
+			parts = append(parts, templatePart{text: lit.String()})
+			lit.Reset()
+		}
+		parts = append(parts, templatePart{text: name, capture: true})
+	}
+	if lit.Len() > 0 {
+		parts = append(parts, templatePart{text: lit.String()})
+	}
+	return parts, nil
+}
+
+// isCaptureChar reports whether c can be part of a $name reference. Dots
+// are left out so that $x.y refers to @x.
+func isCaptureChar(c byte) bool {
+	return c == '_' || c == '-' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// rewriter produces the edits of a query and a replacement template.
+type rewriter struct {
+	hq    *highlightQuery
+	parts []templatePart
+}
+
+func newRewriter(hq *highlightQuery, template string) (*rewriter, error) {
+	parts, err := parseTemplate(template)
+	if err != nil {
+		return nil, fmt.Errorf("template: %w", err)
+	}
+	names := map[string]bool{}
+	for i := uint32(0); i < hq.q.CaptureCount(); i++ {
+		names[hq.q.CaptureNameForId(i)] = true
+	}
+	for _, p := range parts {
+		if p.capture && !names[p.text] {
+			return nil, fmt.Errorf("template: undefined capture @%s", p.text)
+		}
+	}
+	return &rewriter{hq: hq, parts: parts}, nil
+}
+
+// edits returns the edits of the matches in root, in the order of the
+// source. The node a match replaces is its @rewrite capture if the pattern
+// has one, otherwise its widest capture. Matches overlapping an earlier
+// one are left out.
+func (rw *rewriter) edits(root *sitter.Node, code []byte) []Edit {
+	var edits []Edit
+	queryMatches(rw.hq, root, code, func(m *sitter.QueryMatch) {
+		nodes := map[string][]*sitter.Node{}
+		var target *sitter.Node
+		for _, c := range m.Captures {
+			name := rw.hq.q.CaptureNameForId(c.Index)
+			nodes[name] = append(nodes[name], c.Node)
+			if name == "rewrite" {
+				target = c.Node
+			}
+		}
+		if target == nil {
+			for _, c := range m.Captures {
+				if target == nil || c.Node.EndByte()-c.Node.StartByte() > target.EndByte()-target.StartByte() {
+					target = c.Node
+				}
+			}
+		}
+		if target == nil {
+			return
+		}
+		var text strings.Builder
+		for _, p := range rw.parts {
+			if !p.capture {
+				text.WriteString(p.text)
+				continue
+			}
+			// A quantified capture stands for the text from its first node
+			// to its last, separators included.
+			if ns := nodes[p.text]; len(ns) > 0 {
+				text.Write(code[ns[0].StartByte():ns[len(ns)-1].EndByte()])
+			}
+		}
+		edits = append(edits, Edit{
+			Start: Point{Row: target.StartPoint().Row, Column: target.StartPoint().Column},
+			End:   Point{Row: target.EndPoint().Row, Column: target.EndPoint().Column},
+			Text:  text.String(),
+			start: target.StartByte(),
+			end:   target.EndByte(),
+		})
+	})
+
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end > edits[j].end
+	})
+	kept := edits[:0]
+	for _, e := range edits {
+		if len(kept) > 0 && e.start < kept[len(kept)-1].end {
+			continue
+		}
+		if e.Text == string(code[e.start:e.end]) {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// applyEdits returns code with the edits, which are in order and don't
+// overlap, applied.
+func applyEdits(code []byte, edits []Edit) []byte {
+	var b bytes.Buffer
+	prev := uint32(0)
+	for _, e := range edits {
+		b.Write(code[prev:e.start])
+		b.WriteString(e.Text)
+		prev = e.end
+	}
+	b.Write(code[prev:])
+	return b.Bytes()
+}
+
+func doRewrite(ctx context.Context, ps parsers, lname, code, querySrc, template string) ([]Edit, error) {
+	parser, lang, err := ps.get(lname)
+	if err != nil {
+		return nil, err
+	}
+	hq, err := compileQuery([]byte(querySrc), lang)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	rw, err := newRewriter(hq, template)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := parse(ctx, parser, []byte(code))
+	if err != nil {
+		return nil, err
+	}
+	return rw.edits(tree.RootNode(), []byte(code)), nil
+}
+
+// diffContext is the number of unchanged lines around the changes in a
+// diff.
+const diffContext = 3
+
+// writeDiff writes the edits of code as a unified diff. Edits which are
+// less than two contexts apart share a hunk, and the lines they touch are
+// removed and added as a whole.
+func writeDiff(w io.Writer, file string, code []byte, edits []Edit) {
+	if len(edits) == 0 {
+		return
+	}
+	starts := []int{0}
+	for i, c := range code {
+		if c == '\n' && i+1 < len(code) {
+			starts = append(starts, i+1)
+		}
+	}
+	lineOf := func(offset int) int {
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > offset }) - 1
+	}
+	lineEnd := func(line int) int {
+		if line+1 < len(starts) {
+			return starts[line+1]
+		}
+		return len(code)
+	}
+	writeLines := func(prefix string, text []byte) {
+		for len(text) > 0 {
+			line := text
+			if i := bytes.IndexByte(text, '\n'); i >= 0 {
+				line = text[:i+1]
+			}
+			text = text[len(line):]
+			io.WriteString(w, prefix)
+			w.Write(line)
+			if line[len(line)-1] != '\n' {
+				io.WriteString(w, "\n\\ No newline at end of file\n")
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "--- a/%s\n+++ b/%s\n", file, file)
+	delta := 0
+	for i := 0; i < len(edits); {
+		// The lines the edits of this hunk touch.
+		first := lineOf(int(edits[i].start))
+		last := first
+		j := i
+		for ; j < len(edits); j++ {
+			e := edits[j]
+			if lineOf(int(e.start)) > last+2*diffContext && j > i {
+				break
+			}
+			end := int(e.end)
+			if end > int(e.start) && !joinsLines(code, e) {
+				end--
+			}
+			if l := lineOf(end); l > last {
+				last = l
+			}
+		}
+		from, to := starts[first], lineEnd(last)
+		var changed bytes.Buffer
+		prev := from
+		for _, e := range edits[i:j] {
+			changed.Write(code[prev:e.start])
+			changed.WriteString(e.Text)
+			prev = int(e.end)
+		}
+		changed.Write(code[prev:to])
+
+		before := first - diffContext
+		if before < 0 {
+			before = 0
+		}
+		after := last + diffContext
+		if after >= len(starts) {
+			after = len(starts) - 1
+		}
+		oldLines := after - before + 1
+		newLines := oldLines - (last - first + 1) + countLines(changed.Bytes())
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", before+1, oldLines, before+1+delta, newLines)
+		writeLines(" ", code[starts[before]:from])
+		writeLines("-", code[from:to])
+		writeLines("+", changed.Bytes())
+		writeLines(" ", code[to:lineEnd(after)])
+		delta += newLines - oldLines
+		i = j
+	}
+}
+
+// countLines returns the number of lines of text, counting a last line
+// without a newline.
+// joinsLines reports whether e removes the newline at its end without
+// putting one back, so that the line after it becomes part of the edit.
+func joinsLines(code []byte, e Edit) bool {
+	if code[e.end-1] != '\n' || strings.HasSuffix(e.Text, "\n") {
+		return false
+	}
+	// Whole lines removed leave the next line as it is.
+	return e.Text != "" || e.start > 0 && code[e.start-1] != '\n'
+}
+
+func countLines(text []byte) int {
+	n := bytes.Count(text, []byte("\n"))
+	if len(text) > 0 && text[len(text)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+func rewriteCommand(args []string) int {
+	flags := flag.NewFlagSet("rewrite", flag.ExitOnError)
+	var lname string
+	var write, diff, edits bool
+	flags.StringVar(&lname, "lang", "", "rewrite files of this language only (default: detected)")
+	flags.StringVar(&lname, "l", "", "shorthand for -lang")
+	flags.BoolVar(&write, "w", false, "write the rewritten files instead of printing them")
+	flags.BoolVar(&diff, "d", false, "print a diff of the changes instead of the rewritten files")
+	flags.BoolVar(&edits, "edits", false, "print the edits of each file as a line of JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s rewrite [-l lang] [-w|-d|-edits] query template [path...]\n", name)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return 2
+	}
+	if lname != "" {
+		l := lookupLanguage(lname)
+		if l == "" {
+			fmt.Fprintf(os.Stderr, "rewrite: %v: %s\n", errUnknownLanguage, lname)
+			return 2
+		}
+		lname = l
+	}
+	querySrc, template := flags.Arg(0), flags.Arg(1)
+	files := []string{"-"}
+	if flags.NArg() > 2 {
+		var err error
+		if files, err = grepFiles(flags.Args()[2:], lname); err != nil {
+			fmt.Fprintf(os.Stderr, "rewrite: %v\n", err)
+			return 2
+		}
+	} else if write {
+		fmt.Fprintln(os.Stderr, "rewrite: -w needs files")
+		return 2
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	enc := json.NewEncoder(w)
+	ps := parsers{}
+	status := 0
+	for _, file := range files {
+		code, l, err := readSource(file, lname)
+		var es []Edit
+		if err == nil {
+			es, err = doRewrite(context.Background(), ps, l, code, querySrc, template)
+		}
+		if err != nil {
+			w.Flush()
+			fmt.Fprintf(os.Stderr, "rewrite: %s: %v\n", file, err)
+			status = 2
+			continue
+		}
+		switch {
+		case edits:
+			enc.Encode(struct {
+				File  string `json:"file"`
+				Edits []Edit `json:"edits"`
+			}{file, append([]Edit{}, es...)})
+		case diff:
+			writeDiff(w, file, []byte(code), es)
+		case write:
+			if len(es) == 0 {
+				continue
+			}
+			if err := writeFile(file, applyEdits([]byte(code), es)); err != nil {
+				fmt.Fprintf(os.Stderr, "rewrite: %v\n", err)
+				status = 2
+			}
+		default:
+			w.Write(applyEdits([]byte(code), es))
+		}
+	}
+	return status
+}
+
+// writeFile replaces the content of file keeping its permissions.
+func writeFile(file string, b []byte) error {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, fi.Mode().Perm())
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []templatePart
+	}{
+		{"$f(${args})", []templatePart{{"f", true}, {"(", false}, {"args", true}, {")", false}}},
+		{"a$$b $ $1x", []templatePart{{"a$b $ ", false}, {"1x", true}}},
+		{"$x.y$", []templatePart{{"x", true}, {".y$", false}}},
+		{"${a.b}", []templatePart{{"a.b", true}}},
+	} {
+		got, err := parseTemplate(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTemplate(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"${x", "${}"} {
+		if _, err := parseTemplate(in); err == nil {
+			t.Errorf("parseTemplate(%q) succeeded", in)
+		}
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	code := []byte("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\no")
+	edits := []Edit{
+		{Text: "B", start: 2, end: 3},
+		{Text: "C\nC", start: 4, end: 5},
+		{Text: "", start: 27, end: 28},
+		{Text: "O", start: 28, end: 29},
+	}
+	if got := string(applyEdits(code, edits)); got != "a\nB\nC\nC\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nnO" {
+		t.Errorf("applyEdits: %q", got)
+	}
+	var buf bytes.Buffer
+	writeDiff(&buf, "x.txt", code, edits)
+	want := `--- a/x.txt
++++ b/x.txt
+@@ -1,6 +1,7 @@
+ a
+-b
+-c
++B
++C
++C
+ d
+ e
+ f
+@@ -11,5 +12,4 @@
+ k
+ l
+ m
+-n
+-o
+\ No newline at end of file
++nO
+\ No newline at end of file
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteDiffJoin(t *testing.T) {
+	code := []byte("a\nb\nc\nd\n")
+	for _, tt := range []struct {
+		edit Edit
+		want string
+	}{
+		// The removed newline joins c to b.
+		{Edit{Text: "", start: 3, end: 4}, "@@ -1,4 +1,3 @@\n a\n-b\n-c\n+bc\n d\n"},
+		{Edit{Text: "B", start: 2, end: 4}, "@@ -1,4 +1,3 @@\n a\n-b\n-c\n+Bc\n d\n"},
+		// A whole line removed doesn't touch the next one.
+		{Edit{Text: "", start: 2, end: 4}, "@@ -1,4 +1,3 @@\n a\n-b\n c\n d\n"},
+		{Edit{Text: "", start: 7, end: 8}, "@@ -1,4 +1,4 @@\n a\n b\n c\n-d\n+d\n\\ No newline at end of file\n"},
+	} {
+		var buf bytes.Buffer
+		writeDiff(&buf, "x.txt", code, []Edit{tt.edit})
+		want := "--- a/x.txt\n+++ b/x.txt\n" + tt.want
+		if buf.String() != want {
+			t.Errorf("%+v: got:\n%s\nwant:\n%s", tt.edit, buf.String(), want)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	ps := parsers{}
+	code := "package main\n\nfunc main() {\n\tlog.Fatal(a, b)\n\tlog.Print(c)\n}\n"
+	edits, err := doRewrite(context.Background(), ps, "go", code,
+		`((call_expression function: (selector_expression field: (field_identifier) @f) arguments: (argument_list ((_) @args ("," (_) @args)*))) @call (#eq? @f "Fatal"))`,
+		`fatal(${args})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Edit{{Start: Point{Row: 3, Column: 1}, End: Point{Row: 3, Column: 16}, Text: "fatal(a, b)", start: 29, end: 44}}
+	if !reflect.DeepEqual(edits, want) {
+		t.Errorf("edits %+v; want %+v", edits, want)
+	}
+
+	for _, tt := range []struct{ query, template string }{
+		{"(identifier) @x", "$y"},
+		{"(identifier", "$x"},
+		{"(nothing) @x", "$x"},
+	} {
+		if _, err := doRewrite(context.Background(), ps, "go", code, tt.query, tt.template); err == nil {
+			t.Errorf("%s -> %s: no error", tt.query, tt.template)
+		}
+	}
+}
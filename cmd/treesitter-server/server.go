@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+func readLine(reader *bufio.Reader, buf *bytes.Buffer) error {
+	for {
+		b, prefix, err := reader.ReadLine()
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		if !prefix {
+			break
+		}
+	}
+	return nil
+}
+
+var errInvalidRequest = errors.New("invalid request")
+
+func decodeRequest(b []byte) ([]string, error) {
+	var input []string
+	if err := json.Unmarshal(b, &input); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidRequest, err)
+	}
+	if len(input) == 0 {
+		return nil, fmt.Errorf("%w: empty", errInvalidRequest)
+	}
+	return input, nil
+}
+
+func position(s string) (uint32, error) {
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid position: %q", s)
+	}
+	return uint32(n), nil
+}
+
+// requestBuffer returns the buffer a request is about, or "" if the request
+// doesn't name one.
+//
+//	["syntax", filetype, text, buffer, filename]
+//	["textobj", filetype, text, column, line, buffer, filename]
+//	["rewrite", filetype, text, query, template, buffer, filename]
+func requestBuffer(input []string) string {
+	switch {
+	case input[0] == "syntax" && len(input) >= 4:
+		return input[3]
+	case input[0] == "textobj" && len(input) >= 6:
+		return input[5]
+	case input[0] == "rewrite" && len(input) >= 6:
+		return input[5]
+	}
+	return ""
+}
+
+// requestLanguage returns the language of the text of a syntax or textobj
+// request, detected from the file name and the text when the filetype isn't
+// known.
+func requestLanguage(input []string, filename int) string {
+	name := ""
+	if len(input) > filename {
+		name = input[filename]
+	}
+	if l := detectLanguage(input[1], name, input[2]); l != "" {
+		return l
+	}
+	return input[1]
+}
+
+// handle runs a request and returns the response, or nil if the request
+// has no response.
+func handle(ctx context.Context, ps parsers, input []string) *Response {
+	switch input[0] {
+	case "version":
+		return &Response{"version", version}
+	case "reload_queries":
+		reloadQueries()
+		return &Response{"reload_queries", "ok"}
+	case "detect":
+		if len(input) != 3 && len(input) != 4 {
+			return &Response{"error", "detect: wrong number of arguments"}
+		}
+		text := ""
+		if len(input) == 4 {
+			text = input[3]
+		}
+		return &Response{"detect", detectLanguage(input[1], input[2], text)}
+	case "syntax":
+		if len(input) < 3 || len(input) > 5 {
+			return &Response{"error", "syntax: wrong number of arguments"}
+		}
+		props, err := doSyntax(ctx, ps, requestLanguage(input, 4), input[2])
+		if errors.Is(err, errUnknownLanguage) || errors.Is(err, errCanceled) {
+			return nil
+		} else if err != nil {
+			return &Response{"error", "syntax: " + err.Error()}
+		}
+		if len(input) >= 4 {
+			return &Response{"syntax", props, input[3]}
+		}
+		return &Response{"syntax", props}
+	case "textobj":
+		if len(input) < 5 || len(input) > 7 {
+			return &Response{"error", "textobj: wrong number of arguments"}
+		}
+		col, err := position(input[3])
+		if err != nil {
+			return &Response{"error", "textobj: " + err.Error()}
+		}
+		line, err := position(input[4])
+		if err != nil {
+			return &Response{"error", "textobj: " + err.Error()}
+		}
+		node, err := doTextObj(ctx, ps, requestLanguage(input, 6), input[2], col, line)
+		if errors.Is(err, errUnknownLanguage) || errors.Is(err, errCanceled) {
+			return nil
+		} else if err != nil {
+			return &Response{"error", "textobj: " + err.Error()}
+		}
+		if node == nil {
+			return &Response{"textobj", "not found"}
+		}
+		return &Response{"textobj", node}
+	case "rewrite":
+		if len(input) < 5 || len(input) > 7 {
+			return &Response{"error", "rewrite: wrong number of arguments"}
+		}
+		edits, err := doRewrite(ctx, ps, requestLanguage(input, 6), input[2], input[3], input[4])
+		if errors.Is(err, errCanceled) {
+			return nil
+		} else if err != nil {
+			return &Response{"error", "rewrite: " + err.Error()}
+		}
+		if edits == nil {
+			edits = []Edit{}
+		}
+		if len(input) >= 6 {
+			return &Response{"rewrite", edits, input[5]}
+		}
+		return &Response{"rewrite", edits}
+	}
+	return &Response{"error", "invalid command"}
+}
+
+// safeHandle is handle which turns a panic into an error response, so that
+// one bad request doesn't take down the server.
+func safeHandle(ctx context.Context, ps parsers, input []string) (res *Response) {
+	defer func() {
+		if r := recover(); r != nil {
+			ps.reset()
+			stack := make([]byte, 64<<10)
+			stack = stack[:runtime.Stack(stack, false)]
+			res = &Response{"error", fmt.Sprintf("panic: %v\n%s", r, stack)}
+		}
+	}()
+	return handle(ctx, ps, input)
+}
+
+type request struct {
+	input  []string
+	id     int64
+	buffer string
+	gen    uint64
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// bufferState tracks the latest syntax request of a buffer so that older
+// ones can be dropped or cancelled.
+type bufferState struct {
+	gen    uint64
+	cancel context.CancelFunc
+}
+
+// workers is the number of requests a session handles at the same time.
+var workers = runtime.NumCPU()
+
+// session is the state of one client. Requests are spread over workers,
+// each with its own parsers since parsers can't be shared between
+// goroutines. All requests for a buffer go to the same worker so they are
+// answered in order.
+type session struct {
+	mu      sync.Mutex
+	buffers map[string]*bufferState
+	queues  []chan *request
+	next    int
+
+	wmu   sync.Mutex
+	codec codec
+}
+
+func newSession(c codec) *session {
+	n := workers
+	if n < 1 {
+		n = 1
+	}
+	s := &session{
+		buffers: map[string]*bufferState{},
+		codec:   c,
+	}
+	for i := 0; i < n; i++ {
+		s.queues = append(s.queues, make(chan *request, 256))
+	}
+	return s
+}
+
+// write sends the response to a request. Only requests with an id are
+// answered when there is no response. A response which can't be sent is
+// replaced by the error, since the client may be waiting for an answer,
+// and reported if that fails too.
+func (s *session) write(id int64, res *Response) {
+	if res == nil && id == noID {
+		return
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if err := s.codec.writeResponse(id, res); err != nil {
+		if err := s.codec.writeResponse(id, &Response{"error", err.Error()}); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		}
+	}
+}
+
+// supersede makes req the latest syntax request of its buffer and cancels
+// the one in flight.
+func (s *session) supersede(req *request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buffers[req.buffer]
+	if !ok {
+		b = &bufferState{}
+		s.buffers[req.buffer] = b
+	}
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.gen++
+	b.cancel = req.cancel
+	req.gen = b.gen
+}
+
+// obsolete reports whether a newer syntax request arrived for the buffer
+// of req.
+func (s *session) obsolete(req *request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buffers[req.buffer]
+	return ok && b.gen != req.gen
+}
+
+// done releases req. When it is the latest syntax request of its buffer
+// nothing is left in flight for the buffer and its state is dropped.
+func (s *session) done(req *request) {
+	req.cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.buffers[req.buffer]; ok && b.gen == req.gen {
+		delete(s.buffers, req.buffer)
+	}
+}
+
+func (s *session) dispatch(req *request) {
+	var i int
+	if req.buffer != "" {
+		h := fnv.New32a()
+		h.Write([]byte(req.buffer))
+		i = int(h.Sum32() % uint32(len(s.queues)))
+	} else {
+		i = s.next % len(s.queues)
+		s.next++
+	}
+	s.queues[i] <- req
+}
+
+// parserPool keeps the parsers of finished sessions for the next ones.
+var parserPool = sync.Pool{
+	New: func() interface{} { return parsers{} },
+}
+
+func (s *session) work(queue chan *request) {
+	ps := parserPool.Get().(parsers)
+	defer parserPool.Put(ps)
+	for req := range queue {
+		if req.gen != 0 && s.obsolete(req) {
+			s.done(req)
+			s.write(req.id, nil)
+			continue
+		}
+		s.write(req.id, safeHandle(req.ctx, ps, req.input))
+		s.done(req)
+	}
+}
+
+// serve reads requests until the input is closed.
+func (s *session) serve() {
+	var wg sync.WaitGroup
+	for _, queue := range s.queues {
+		wg.Add(1)
+		go func(queue chan *request) {
+			defer wg.Done()
+			s.work(queue)
+		}(queue)
+	}
+
+	for {
+		input, id, err := s.codec.readRequest()
+		if errors.Is(err, errInvalidRequest) {
+			s.write(id, &Response{"error", err.Error()})
+			continue
+		} else if err != nil {
+			break
+		}
+		req := &request{input: input, id: id, buffer: requestBuffer(input)}
+		req.ctx, req.cancel = context.WithCancel(context.Background())
+		if req.buffer != "" && input[0] == "syntax" {
+			s.supersede(req)
+		}
+		s.dispatch(req)
+	}
+	for _, queue := range s.queues {
+		close(queue)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"hash/fnv"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionServe(t *testing.T) {
+	in := strings.Join([]string{
+		`["version"]`,
+		`not json`,
+		`["textobj"]`,
+		`["textobj", "go", "", "x", "0"]`,
+		`["nope"]`,
+		`["detect", "sh", "run"]`,
+		`["rewrite", "go", ""]`,
+	}, "\n")
+	var out bytes.Buffer
+	c, _ := newCodec("json", strings.NewReader(in), &out)
+	newSession(c).serve()
+
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	sort.Strings(got)
+	want := []string{
+		`["detect","bash"]`,
+		`["error","invalid command"]`,
+		`["error","invalid request: invalid character 'o' in literal null (expecting 'u')"]`,
+		`["error","rewrite: wrong number of arguments"]`,
+		`["error","textobj: invalid position: \"x\""]`,
+		`["error","textobj: wrong number of arguments"]`,
+		`["version","` + version + `"]`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSessionBufferOrder(t *testing.T) {
+	s := newSession(nil)
+	a := &request{buffer: "1"}
+	b := &request{buffer: "1"}
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	s.supersede(a)
+	s.supersede(b)
+	if a.ctx.Err() == nil {
+		t.Error("superseded request was not cancelled")
+	}
+	if !s.obsolete(a) || s.obsolete(b) {
+		t.Error("only the older request should be obsolete")
+	}
+	s.done(b)
+	if b.ctx.Err() == nil {
+		t.Error("finished request was not released")
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	for _, tt := range []struct {
+		addr, network, address string
+	}{
+		{"unix:/tmp/treesitter.sock", "unix", "/tmp/treesitter.sock"},
+		{"127.0.0.1:7878", "tcp", "127.0.0.1:7878"},
+		{"localhost:0", "tcp", "localhost:0"},
+		{":7878", "tcp", "127.0.0.1:7878"},
+		{"0.0.0.0:7878", "tcp", "0.0.0.0:7878"},
+	} {
+		network, address := listenAddr(tt.addr)
+		if network != tt.network || address != tt.address {
+			t.Errorf("listenAddr(%q) = %q, %q; want %q, %q", tt.addr, network, address, tt.network, tt.address)
+		}
+	}
+}
+
+func TestListenNotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notasocket.txt")
+	if err := os.WriteFile(path, []byte("important"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := listen("unix:" + path); err == nil || err.Error() != path+" is in use and not a socket" {
+		t.Errorf("listen on a regular file: %v", err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "important" {
+		t.Errorf("the file was not left alone: %q, %v", b, err)
+	}
+}
+
+func TestServeListener(t *testing.T) {
+	l, err := net.Listen(listenAddr(":0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- serveListener(l) }()
+
+	type client struct {
+		conn net.Conn
+		r    *bufio.Reader
+	}
+	dial := func() *client {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &client{conn, bufio.NewReader(conn)}
+	}
+	call := func(c *client, req string) string {
+		t.Helper()
+		if _, err := io.WriteString(c.conn, req+"\n"); err != nil {
+			t.Fatal(err)
+		}
+		c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		res, err := c.r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(res)
+	}
+
+	// Both clients are connected at once, each with its own session.
+	a, b := dial(), dial()
+	if got, want := call(a, `["detect", "sh", "run"]`), `["detect","bash"]`; got != want {
+		t.Errorf("first client got %s; want %s", got, want)
+	}
+	if got, want := call(b, `["version"]`), `["version","`+version+`"]`; got != want {
+		t.Errorf("second client got %s; want %s", got, want)
+	}
+	if got, want := call(a, `["syntax", "go", "package p", "1"]`), `["syntax",[[{"row":1,"col":1,"attr":{"length":7,"type":"TSInclude"}},{"row":1,"col":9,"attr":{"length":1,"type":"TSVariable"}}]],"1"]`; got != want {
+		t.Errorf("first client got %s; want %s", got, want)
+	}
+	a.conn.Close()
+	if got, want := call(b, `["nope"]`), `["error","invalid command"]`; got != want {
+		t.Errorf("second client got %s after the first left; want %s", got, want)
+	}
+	b.conn.Close()
+
+	l.Close()
+	if err := <-done; err != nil {
+		t.Errorf("serveListener: %v", err)
+	}
+}
+
+func TestSessionPrune(t *testing.T) {
+	s := newSession(nil)
+	reqs := make([]*request, 3)
+	for i := range reqs {
+		reqs[i] = &request{buffer: strconv.Itoa(i % 2)}
+		reqs[i].ctx, reqs[i].cancel = context.WithCancel(context.Background())
+		s.supersede(reqs[i])
+	}
+	if len(s.buffers) != 2 {
+		t.Fatalf("%d buffers tracked; want 2", len(s.buffers))
+	}
+	// The first request of buffer 0 is superseded by the third one, which
+	// is still in flight.
+	s.done(reqs[0])
+	s.done(reqs[1])
+	if _, ok := s.buffers["0"]; !ok || len(s.buffers) != 1 {
+		t.Errorf("buffers %v; want only 0", s.buffers)
+	}
+	s.done(reqs[2])
+	if len(s.buffers) != 0 {
+		t.Errorf("buffers %v left after all requests finished", s.buffers)
+	}
+
+	// A buffer seen again starts over.
+	req := &request{buffer: "0"}
+	req.ctx, req.cancel = context.WithCancel(context.Background())
+	s.supersede(req)
+	if s.obsolete(req) {
+		t.Error("request of a pruned buffer is obsolete")
+	}
+	s.done(req)
+	if len(s.buffers) != 0 {
+		t.Errorf("buffers %v left", s.buffers)
+	}
+}
+
+// testCodec answers the requests in in and records the responses.
+type testCodec struct {
+	in  [][]string
+	mu  sync.Mutex
+	out []*Response
+}
+
+func (c *testCodec) readRequest() ([]string, int64, error) {
+	if len(c.in) == 0 {
+		return nil, noID, io.EOF
+	}
+	input := c.in[0]
+	c.in = c.in[1:]
+	return input, noID, nil
+}
+
+func (c *testCodec) writeResponse(id int64, res *Response) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.out = append(c.out, res)
+	return nil
+}
+
+func TestSessionWorkers(t *testing.T) {
+	saved := workers
+	defer func() { workers = saved }()
+	workers = 4
+
+	// Find two buffers which go to different workers.
+	queue := func(buffer string) uint32 {
+		h := fnv.New32a()
+		h.Write([]byte(buffer))
+		return h.Sum32() % uint32(workers)
+	}
+	slow, fast := "1", "2"
+	for queue(fast) == queue(slow) {
+		fast += "0"
+	}
+
+	rewrite := func(pkg, buffer, rest string) []string {
+		return []string{"rewrite", "go", "package " + pkg + "\n" + rest, "(package_identifier) @n", "$n!", buffer}
+	}
+	c := &testCodec{}
+	// The slow buffer starts with a large file. The requests after it
+	// wait for it while the other buffer is answered meanwhile.
+	c.in = append(c.in, rewrite("big", slow, strings.Repeat("func f() { g(1, 2, \"x\") }\n", 20000)))
+	const n = 20
+	for i := 0; i < n; i++ {
+		c.in = append(c.in, rewrite("s"+strconv.Itoa(i), slow, ""), rewrite("f"+strconv.Itoa(i), fast, ""))
+	}
+	newSession(c).serve()
+
+	if len(c.out) != 2*n+1 {
+		t.Fatalf("%d responses; want %d", len(c.out), 2*n+1)
+	}
+	got := map[string][]string{}
+	for _, res := range c.out {
+		if (*res)[0] != "rewrite" || len(*res) != 3 {
+			t.Fatalf("unexpected response %v", *res)
+		}
+		edits := (*res)[1].([]Edit)
+		buffer := (*res)[2].(string)
+		got[buffer] = append(got[buffer], edits[0].Text)
+	}
+	want := map[string][]string{slow: {"big!"}}
+	for i := 0; i < n; i++ {
+		want[slow] = append(want[slow], "s"+strconv.Itoa(i)+"!")
+		want[fast] = append(want[fast], "f"+strconv.Itoa(i)+"!")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("responses per buffer:\n%v\nwant:\n%v", got, want)
+	}
+	// The fast buffer didn't wait for the slow one.
+	if (*c.out[0])[2] != fast {
+		t.Errorf("first response for buffer %v; want %s", (*c.out[0])[2], fast)
+	}
+}
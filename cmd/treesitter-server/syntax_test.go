@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mattn/vim-treesitter/internal/query"
+)
+
+var update = flag.Bool("update", false, "update snapshot files")
+
+// samples returns the sample files of the language in testdata.
+func samples(t testing.TB, lname string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", lname, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for _, file := range files {
+		if filepath.Ext(file) != ".golden" {
+			result = append(result, file)
+		}
+	}
+	return result
+}
+
+func sortedLanguages() []string {
+	var names []string
+	for l := range languages {
+		names = append(names, l)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func snapshot(lines [][]Prop) string {
+	var b strings.Builder
+	for _, line := range lines {
+		for _, p := range line {
+			fmt.Fprintf(&b, "%d:%d %d %s\n", p.Row, p.Col, p.Attr.Length, p.Attr.Type)
+		}
+	}
+	return b.String()
+}
+
+func TestSyntaxSnapshots(t *testing.T) {
+	ps := parsers{}
+	for _, lname := range sortedLanguages() {
+		t.Run(lname, func(t *testing.T) {
+			files := samples(t, lname)
+			if len(files) == 0 {
+				t.Fatalf("no samples in testdata/%s", lname)
+			}
+			for _, file := range files {
+				code, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines, err := doSyntax(context.Background(), ps, lname, string(code))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := snapshot(lines)
+				if got == "" {
+					t.Errorf("%s is not highlighted at all", file)
+				}
+
+				golden := file + ".golden"
+				if *update {
+					if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				want, err := os.ReadFile(golden)
+				if os.IsNotExist(err) {
+					t.Errorf("%s has no snapshot (run with -update to create %s)", file, golden)
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != string(want) {
+					t.Errorf("%s differs from %s (run with -update to accept)\ngot:\n%s\nwant:\n%s", file, golden, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBundledQueries(t *testing.T) {
+	dirs, err := bundledQueries.ReadDir("queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range dirs {
+		b, err := bundledQueries.ReadFile("queries/" + dir.Name() + "/highlights.scm")
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		q, err := query.Parse(b)
+		if err != nil {
+			t.Errorf("%s/highlights.scm:%v", dir.Name(), err)
+			continue
+		}
+		for _, pat := range q.Patterns {
+			pat.Walk(func(n *query.Node) bool {
+				for _, c := range n.Captures {
+					if g := query.HighlightGroup(c.Name); !strings.HasPrefix(c.Name, "_") && !has(highlightGroups(), g) {
+						t.Errorf("%s/highlights.scm:%v: no highlight group %s for @%s", dir.Name(), c.Pos, g, c.Name)
+					}
+				}
+				return true
+			})
+		}
+		// Queries of grammars built in must compile as a whole, since
+		// patterns which don't are dropped.
+		if lang, err := getLanguage(dir.Name()); err == nil {
+			if _, err := compileQuery(b, lang); err != nil {
+				t.Errorf("%s/highlights.scm:%v", dir.Name(), err)
+			}
+		}
+	}
+}
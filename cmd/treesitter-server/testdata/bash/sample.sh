@@ -0,0 +1,13 @@
+#!/bin/bash
+# Print a greeting for every argument.
+greet() {
+  local name="$1"
+  echo "Hello, ${name}!"
+}
+
+for arg in "$@"; do
+  if [ -n "$arg" ]; then
+    greet "$arg"
+  fi
+done
+exit 0
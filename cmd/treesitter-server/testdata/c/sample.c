@@ -0,0 +1,17 @@
+#include <stdio.h>
+
+#define MAX 10
+
+/* Sum the numbers up to MAX. */
+static int sum(int n) {
+	int total = 0;
+	for (int i = 0; i < n; i++) {
+		total += i;
+	}
+	return total;
+}
+
+int main(void) {
+	printf("%d\n", sum(MAX));
+	return 0;
+}
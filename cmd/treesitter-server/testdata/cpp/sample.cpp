@@ -0,0 +1,27 @@
+#include <iostream>
+#include <vector>
+
+namespace demo {
+
+// A counter which can only grow.
+class Counter {
+public:
+	explicit Counter(int start) : value_(start) {}
+	void inc() { ++value_; }
+	int value() const { return value_; }
+
+private:
+	int value_;
+};
+
+} // namespace demo
+
+int main() {
+	std::vector<int> xs{1, 2, 3};
+	demo::Counter c(0);
+	for (auto x : xs) {
+		c.inc();
+	}
+	std::cout << c.value() << std::endl;
+	return true ? 0 : 1;
+}
@@ -0,0 +1,29 @@
+// Highlight assertions for the bundled C# queries.
+using System;
+// <- TSKeyword
+//    ^ TSNamespace
+
+namespace Demo
+// <- TSInclude
+{
+    public class Greeter
+    //     ^ TSKeyword
+    //           ^ TSType
+    {
+        public string Greet(string name, int count)
+        //     ^ TSTypeBuiltin
+        //            ^ TSMethod
+        //                         ^ TSParameter
+        {
+            if (count > 0)
+            // <- TSConditional
+            {
+                return "Hello, " + name;
+                // <- TSKeywordReturn
+                //     ^ TSString
+            }
+            return null;
+            //     ^ TSConstBuiltin
+        }
+    }
+}
@@ -0,0 +1,34 @@
+using System;
+using System.Collections.Generic;
+
+namespace Demo
+{
+    // A counter which can only grow.
+    public class Counter
+    {
+        private int value;
+
+        public Counter(int start)
+        {
+            value = start;
+        }
+
+        public void Inc() => value++;
+
+        public int Value { get { return value; } }
+    }
+
+    public static class Program
+    {
+        public static void Main(string[] args)
+        {
+            var xs = new List<int> { 1, 2, 3 };
+            var c = new Counter(0);
+            foreach (var x in xs)
+            {
+                c.Inc();
+            }
+            Console.WriteLine($"count: {c.Value}");
+        }
+    }
+}
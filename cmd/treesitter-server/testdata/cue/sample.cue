@@ -0,0 +1,27 @@
+package config
+
+import "strings"
+
+// A service exposed by the cluster.
+#Service: {
+	name:     string
+	port:     int & >0 & <65536 | *8080
+	replicas: int | *1
+	public:   bool | *false
+	labels: [string]: string
+}
+
+let prefix = "svc-"
+
+services: [Name=string]: #Service & {
+	name: prefix + strings.ToLower(Name)
+}
+
+services: {
+	api: port:    443
+	worker: public: true
+}
+
+ratio:   0.75
+nothing: null
+ports: [for s in services if s.public {s.port}]
@@ -0,0 +1,12 @@
+defmodule Greeter do
+  @moduledoc "Greets people."
+
+  # Says hello.
+  def hello(name) when is_binary(name) do
+    "Hello, #{name}!"
+  end
+
+  defp count(list), do: length(list) + 1
+end
+
+IO.puts(Greeter.hello(:world |> to_string()))
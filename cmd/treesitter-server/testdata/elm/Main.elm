@@ -0,0 +1,22 @@
+module Main exposing (main)
+
+import Html exposing (Html, text)
+
+
+-- Greeting for the given name.
+greet : String -> String
+greet name =
+    "Hello, " ++ name ++ "!"
+
+
+main : Html msg
+main =
+    let
+        count =
+            3
+    in
+    if count > 2 then
+        text (greet "world")
+
+    else
+        text "nothing"
@@ -0,0 +1,29 @@
+// Highlight assertions for the builtin Go highlighting.
+package main
+// <- TSInclude
+
+import "fmt"
+// <- TSInclude
+//     ^ TSString
+
+func main() {
+// <- TSKeywordFunction
+//       ^ TSPunctBracket
+	for i := 0; i < 3; i++ {
+	// <- TSRepeat
+	//    ^ TSOperator
+	//       ^ TSNumber
+	//        ^ TSPunctDelimiter
+		if ok := i > 1; ok == true {
+		// <- TSConditional
+		//                    ^ TSBoolean
+			fmt.Println("big\n", nil, 1.5)
+			//          ^ TSString
+			//              ^ TSStringEscape
+			//                   ^ TSConstBuiltin
+			//                        ^ TSFloat
+		}
+	}
+	return
+	// <- TSKeywordReturn
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Counter counts things.
+type Counter struct {
+	n int
+}
+
+func (c *Counter) Inc() {
+	c.n++
+}
+
+const limit = 10
+
+func main() {
+	var c Counter
+	for i := 0; i < limit; i++ {
+		c.Inc()
+	}
+	if c.n != limit {
+		fmt.Fprintln(os.Stderr, "unexpected count:", c.n)
+		os.Exit(1)
+	}
+	s := "done\n"
+	fmt.Print(s, true, nil, 1.5)
+}
@@ -0,0 +1,20 @@
+# A sample Terraform configuration.
+variable "region" {
+  type    = string
+  default = "us-east-1"
+}
+
+resource "aws_instance" "web" {
+  ami           = "ami-123456"
+  instance_type = "t3.micro"
+  count         = 2
+  monitoring    = true
+
+  tags = {
+    Name = "web-${count.index}"
+  }
+}
+
+output "ids" {
+  value = [for i in aws_instance.web : i.id]
+}
@@ -0,0 +1,28 @@
+package demo;
+
+import java.util.List;
+
+/** A counter which can only grow. */
+public class Sample {
+    private int value;
+
+    public Sample(int start) {
+        this.value = start;
+    }
+
+    @Override
+    public String toString() {
+        return "Sample(" + value + ")";
+    }
+
+    public static void main(String[] args) {
+        List<Integer> xs = List.of(1, 2, 3);
+        Sample s = new Sample(0);
+        for (int x : xs) {
+            s.value += x;
+        }
+        if (s.value > 5 && true) {
+            System.out.println(s);
+        }
+    }
+}
@@ -0,0 +1,20 @@
+// Fetch and print a list of users.
+import { get } from "./http.js";
+
+const LIMIT = 10;
+
+class Users {
+  constructor(base) {
+    this.base = base;
+  }
+
+  async list() {
+    const res = await get(`${this.base}/users?limit=${LIMIT}`);
+    return res.items.filter((u) => u.active !== false);
+  }
+}
+
+export default function main() {
+  const users = new Users("https://example.com");
+  users.list().then((xs) => console.log(xs.length, null, /a+b/g));
+}
@@ -0,0 +1,15 @@
+package sample
+
+import kotlin.math.max
+
+// Greets people.
+class Greeter(private val name: String) {
+    fun greet(times: Int): String {
+        val n = max(times, 1)
+        return "Hello, $name! ".repeat(n)
+    }
+}
+
+fun main() {
+    if (true) println(Greeter("world").greet(2)) else return
+}
@@ -0,0 +1,23 @@
+-- A tiny counter module.
+local M = {}
+
+local function clamp(n, max)
+  if n > max then
+    return max
+  end
+  return n
+end
+
+function M.new(start)
+  local self = { value = start or 0 }
+  function self:inc()
+    self.value = clamp(self.value + 1, 100)
+  end
+  return self
+end
+
+for i = 1, 3 do
+  print("count", i, nil, true)
+end
+
+return M
@@ -0,0 +1,17 @@
+(* Sum a list of integers. *)
+let rec sum = function
+  | [] -> 0
+  | x :: xs -> x + sum xs
+
+type shape =
+  | Circle of float
+  | Square of float
+
+let area = function
+  | Circle r -> 3.14 *. r *. r
+  | Square s -> s *. s
+
+let () =
+  let total = sum [1; 2; 3] in
+  Printf.printf "%d %f\n" total (area (Circle 1.0));
+  if total > 5 && true then print_endline "big"
@@ -0,0 +1,28 @@
+<?php
+
+namespace Demo;
+
+// A counter which can only grow.
+class Counter
+{
+    private int $value = 0;
+
+    public function inc(): void
+    {
+        $this->value++;
+    }
+
+    public function value(): int
+    {
+        return $this->value;
+    }
+}
+
+$c = new Counter();
+foreach ([1, 2, 3] as $x) {
+    $c->inc();
+}
+echo "count: {$c->value()}\n";
+if ($c->value() > 2 && true) {
+    echo null ?? 'none';
+}
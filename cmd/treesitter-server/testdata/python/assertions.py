@@ -0,0 +1,21 @@
+# Highlight assertions for the builtin Python highlighting.
+import os
+# <- TSInclude
+
+
+def main(argv):
+# <- TSKeywordFunction
+#             ^ TSPunctDelimiter
+    for arg in argv:
+    # <- TSRepeat
+    #       ^ TSKeywordOperator
+        if arg == "-v" and True:
+        # <- TSConditional
+        #         ^ TSString
+        #              ^ TSKeywordOperator
+        #                  ^ TSBoolean
+            return 1.5
+            # <- TSKeywordReturn
+            #      ^ TSFloat
+    return None
+    #      ^ TSConstBuiltin
@@ -0,0 +1,26 @@
+"""A counter which can only grow."""
+import sys
+from dataclasses import dataclass
+
+
+@dataclass
+class Counter:
+    value: int = 0
+
+    def inc(self, step=1):
+        # Negative steps are ignored.
+        if step > 0:
+            self.value += step
+        return self
+
+
+def main(argv):
+    c = Counter()
+    for arg in argv[1:]:
+        c.inc(int(arg))
+    print(f"count: {c.value}", None, True)
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main(sys.argv))
@@ -0,0 +1,21 @@
+# A counter which can only grow.
+require 'set'
+
+module Demo
+  class Counter
+    attr_reader :value
+
+    def initialize(start = 0)
+      @value = start
+    end
+
+    def inc(step = 1)
+      @value += step if step.positive?
+      self
+    end
+  end
+end
+
+c = Demo::Counter.new
+[1, 2, 3].each { |x| c.inc(x) }
+puts "count: #{c.value}", nil, true, :done
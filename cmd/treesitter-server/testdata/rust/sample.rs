@@ -0,0 +1,30 @@
+use std::fmt;
+
+/// A counter which can only grow.
+#[derive(Debug, Default)]
+pub struct Counter {
+    value: u32,
+}
+
+impl Counter {
+    pub fn inc(&mut self) -> &mut Self {
+        self.value += 1;
+        self
+    }
+}
+
+impl fmt::Display for Counter {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "count: {}", self.value)
+    }
+}
+
+fn main() {
+    let mut c = Counter::default();
+    for _ in 0..3 {
+        c.inc();
+    }
+    if c.value > 2 && true {
+        println!("{}", c);
+    }
+}
@@ -0,0 +1,21 @@
+package demo
+
+import scala.collection.mutable
+
+// A counter which can only grow.
+class Counter(private var value: Int = 0) {
+  def inc(step: Int = 1): Counter = {
+    if (step > 0) value += step
+    this
+  }
+
+  override def toString: String = s"count: $value"
+}
+
+object Sample {
+  def main(args: Array[String]): Unit = {
+    val c = new Counter()
+    for (x <- List(1, 2, 3)) c.inc(x)
+    println(c)
+  }
+}
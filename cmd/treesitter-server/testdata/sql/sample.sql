@@ -0,0 +1,7 @@
+-- users with orders
+SELECT u.name, COUNT(o.id) AS orders
+FROM users u
+LEFT JOIN orders o ON o.user_id = u.id
+WHERE u.active = TRUE AND u.name LIKE 'a%'
+GROUP BY u.name
+ORDER BY orders DESC;
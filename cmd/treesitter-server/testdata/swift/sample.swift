@@ -0,0 +1,14 @@
+import Foundation
+
+// Greets people.
+struct Greeter {
+    let name: String
+
+    func greet(times: Int) -> String {
+        guard times > 0 else { return "" }
+        return String(repeating: "Hello, \(name)! ", count: times)
+    }
+}
+
+let g = Greeter(name: "world")
+print(g.greet(times: 2), nil ?? 1.5, true)
@@ -0,0 +1,17 @@
+// A counter component.
+import React, { useState } from "react";
+
+type Props = {
+  label: string;
+  start?: number;
+};
+
+export function Counter({ label, start = 0 }: Props): JSX.Element {
+  const [count, setCount] = useState<number>(start);
+  return (
+    <div className="counter">
+      <span>{label}: {count}</span>
+      <button onClick={() => setCount(count + 1)}>+</button>
+    </div>
+  );
+}
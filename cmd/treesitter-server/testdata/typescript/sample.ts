@@ -0,0 +1,33 @@
+// A counter which can only grow.
+import { EventEmitter } from "events";
+
+export interface Options {
+  start?: number;
+  readonly max: number;
+}
+
+enum Mode {
+  Fast,
+  Slow,
+}
+
+export class Counter extends EventEmitter {
+  private value: number;
+
+  constructor(private opts: Options) {
+    super();
+    this.value = opts.start ?? 0;
+  }
+
+  inc(step: number = 1): this {
+    if (this.value + step <= this.opts.max) {
+      this.value += step;
+      this.emit("change", this.value);
+    }
+    return this;
+  }
+}
+
+const c = new Counter({ max: 10 });
+c.inc().inc(2);
+console.log(Mode.Fast, c, null, true);
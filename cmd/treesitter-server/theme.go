@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// style is how a highlight group is rendered outside of Vim. fg is a color
+// of the 256 color palette ("208") or a true color ("#ff8700"), or "" for
+// the default color.
+type style struct {
+	fg        string
+	bold      bool
+	italic    bool
+	underline bool
+	strike    bool
+}
+
+// theme maps highlight groups to styles.
+type theme map[string]style
+
+// defaultTheme is a dark theme in the format of -theme files: a group, a
+// color or "none" and attributes on each line, and comments starting with
+// "#".
+const defaultTheme = `
+TSAnnotation         180
+TSAttribute          180
+TSBoolean            173
+TSCharacter          114
+TSComment            244 italic
+TSConditional        170
+TSConstBuiltin       173
+TSConstMacro         173
+TSConstant           173
+TSConstructor        180
+TSDanger             196 bold
+TSEmphasis           none italic
+TSEnvironment        170
+TSEnvironmentName    180
+TSError              none
+TSException          170
+TSField              174
+TSFloat              173
+TSFuncBuiltin        75
+TSFuncMacro          75
+TSFunction           75
+TSFunctionBuiltin    75
+TSFunctionMacro      75
+TSInclude            170
+TSKeyword            170
+TSKeywordFunction    170
+TSKeywordOperator    170
+TSKeywordReturn      170
+TSLabel              170
+TSLiteral            114
+TSMath               173
+TSMethod             75
+TSNamespace          180
+TSNone               none
+TSNote               75 bold
+TSNumber             173
+TSOperator           110
+TSParameter          216
+TSParameterReference 216
+TSProperty           174
+TSPunctBracket       249
+TSPunctDelimiter     249
+TSPunctSpecial       110
+TSRepeat             170
+TSStrike             none strikethrough
+TSString             114
+TSStringEscape       180
+TSStringRegex        180
+TSStringSpecial      180
+TSStrong             none bold
+TSSymbol             173
+TSTag                168
+TSTagAttribute       180
+TSTagDelimiter       249
+TSText               none
+TSTextReference      173
+TSTitle              75 bold
+TSType               180
+TSTypeBuiltin        180
+TSURI                75 underline
+TSUnderline          none underline
+TSVariable           none
+TSVariableBuiltin    173
+TSWarning            214 bold
+`
+
+// parseTheme reads a theme into t, replacing the styles of the groups it
+// names. Any group the highlighter produces can be styled.
+func parseTheme(t theme, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return fmt.Errorf("theme:%d: missing color for %s", n, fields[0])
+		}
+		if !has(highlightGroups(), fields[0]) {
+			return fmt.Errorf("theme:%d: unknown highlight group %s", n, fields[0])
+		}
+		var s style
+		switch fg := fields[1]; {
+		case fg == "none":
+		case strings.HasPrefix(fg, "#"):
+			if _, err := strconv.ParseUint(fg[1:], 16, 32); err != nil || len(fg) != 7 {
+				return fmt.Errorf("theme:%d: invalid color %s", n, fg)
+			}
+			s.fg = strings.ToLower(fg)
+		default:
+			if c, err := strconv.Atoi(fg); err != nil || c < 0 || c > 255 {
+				return fmt.Errorf("theme:%d: invalid color %s", n, fg)
+			}
+			s.fg = fg
+		}
+		for _, attr := range fields[2:] {
+			switch attr {
+			case "bold":
+				s.bold = true
+			case "italic":
+				s.italic = true
+			case "underline":
+				s.underline = true
+			case "strikethrough":
+				s.strike = true
+			default:
+				return fmt.Errorf("theme:%d: unknown attribute %s", n, attr)
+			}
+		}
+		t[fields[0]] = s
+	}
+	return scanner.Err()
+}
+
+// loadTheme returns the default theme with the styles of the file, if
+// any, applied on top.
+func loadTheme(file string) (theme, error) {
+	t := theme{}
+	if err := parseTheme(t, strings.NewReader(defaultTheme)); err != nil {
+		return nil, err
+	}
+	if file == "" {
+		return t, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := parseTheme(t, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return t, nil
+}
+
+// sgr returns the parameters of the ANSI escape sequence selecting s.
+func (s style) sgr() string {
+	var params []string
+	if s.bold {
+		params = append(params, "1")
+	}
+	if s.italic {
+		params = append(params, "3")
+	}
+	if s.underline {
+		params = append(params, "4")
+	}
+	if s.strike {
+		params = append(params, "9")
+	}
+	if strings.HasPrefix(s.fg, "#") {
+		r, g, b := hexColor(s.fg)
+		params = append(params, fmt.Sprintf("38;2;%d;%d;%d", r, g, b))
+	} else if s.fg != "" {
+		params = append(params, "38;5;"+s.fg)
+	}
+	return strings.Join(params, ";")
+}
+
+func hexColor(s string) (r, g, b int) {
+	n, _ := strconv.ParseUint(s[1:], 16, 32)
+	return int(n >> 16 & 0xff), int(n >> 8 & 0xff), int(n & 0xff)
+}
+
+// rgb returns the color of s as #rrggbb, converting colors of the 256
+// color palette with the values of xterm.
+func (s style) rgb() string {
+	if s.fg == "" || strings.HasPrefix(s.fg, "#") {
+		return s.fg
+	}
+	c, _ := strconv.Atoi(s.fg)
+	var r, g, b int
+	switch {
+	case c < 16:
+		base := [16][3]int{
+			{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+			{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+			{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+			{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+		}
+		r, g, b = base[c][0], base[c][1], base[c][2]
+	case c < 232:
+		level := func(n int) int {
+			if n == 0 {
+				return 0
+			}
+			return 55 + 40*n
+		}
+		c -= 16
+		r, g, b = level(c/36), level(c/6%6), level(c%6)
+	default:
+		r = 8 + 10*(c-232)
+		g, b = r, r
+	}
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+// css returns the declarations of a style sheet rule for s.
+func (s style) css() string {
+	var decls []string
+	if c := s.rgb(); c != "" {
+		decls = append(decls, "color: "+c)
+	}
+	if s.bold {
+		decls = append(decls, "font-weight: bold")
+	}
+	if s.italic {
+		decls = append(decls, "font-style: italic")
+	}
+	var lines []string
+	if s.underline {
+		lines = append(lines, "underline")
+	}
+	if s.strike {
+		lines = append(lines, "line-through")
+	}
+	if len(lines) > 0 {
+		decls = append(decls, "text-decoration: "+strings.Join(lines, " "))
+	}
+	return strings.Join(decls, "; ")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseTheme(t *testing.T) {
+	th, err := loadTheme("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parseTheme(th, strings.NewReader("# mine\nTSKeyword #FF8700 bold\nTSString none\nTSVariable 81\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := th["TSKeyword"].sgr(); got != "1;38;2;255;135;0" {
+		t.Errorf("TSKeyword: got %q", got)
+	}
+	if got := th["TSString"].sgr(); got != "" {
+		t.Errorf("TSString: got %q", got)
+	}
+	if got := th["TSComment"].sgr(); got != "3;38;5;244" {
+		t.Errorf("TSComment: got %q", got)
+	}
+	if got := th["TSVariable"].sgr(); got != "38;5;81" {
+		t.Errorf("TSVariable: got %q", got)
+	}
+	// Every group the highlighter produces has a default style.
+	for _, g := range highlightGroups() {
+		if _, ok := th[g]; !ok {
+			t.Errorf("no default style for %s", g)
+		}
+	}
+
+	for _, tt := range []struct{ in, want string }{
+		{"TSKeyword", "theme:1: missing color for TSKeyword"},
+		{"\nTSNothing 1", "theme:2: unknown highlight group TSNothing"},
+		{"TSKeyword 256", "theme:1: invalid color 256"},
+		{"TSKeyword #fff", "theme:1: invalid color #fff"},
+		{"TSKeyword 1 blink", "theme:1: unknown attribute blink"},
+	} {
+		err := parseTheme(theme{}, strings.NewReader(tt.in))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got %v, want %s", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestStyleRGB(t *testing.T) {
+	for fg, want := range map[string]string{
+		"":        "",
+		"#00ff00": "#00ff00",
+		"1":       "#cd0000",
+		"208":     "#ff8700",
+		"244":     "#808080",
+	} {
+		if got := (style{fg: fg}).rgb(); got != want {
+			t.Errorf("%q: got %q, want %q", fg, got, want)
+		}
+	}
+}
+
+func TestRenderANSI(t *testing.T) {
+	th := theme{"TSKeyword": {fg: "1"}, "TSComment": {italic: true}, "TSNone": {}}
+	lines := [][]Prop{
+		{{Col: 1, Attr: PropAttr{Length: 7, Type: "TSKeyword"}}, {Col: 9, Attr: PropAttr{Length: 4, Type: "TSNone"}}},
+		{},
+		{{Col: 3, Attr: PropAttr{Length: EOL, Type: "TSComment"}}},
+	}
+	var buf bytes.Buffer
+	renderANSI(&buf, "package main\n\n  // x", lines, th)
+	want := "\x1b[38;5;1mpackage\x1b[0m main\n\n  \x1b[3m// x\x1b[0m"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
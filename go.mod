@@ -1,5 +1,5 @@
 module github.com/mattn/vim-treesitter
 
-go 1.18
+go 1.20
 
-require github.com/smacker/go-tree-sitter v0.0.0-20220404154745-d5f0a70b82af
+require github.com/smacker/go-tree-sitter v0.0.0-20240827094217-dd81d9e9be82
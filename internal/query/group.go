@@ -0,0 +1,33 @@
+package query
+
+import (
+	"strings"
+)
+
+// HighlightGroup returns the Vim highlight group for a capture name, for
+// example "TSKeywordFunction" for "keyword.function".
+func HighlightGroup(capture string) string {
+	isToUpper := false
+	r := ""
+	for k, v := range capture {
+		if k == 0 {
+			r = strings.ToUpper(string(capture[0]))
+		} else {
+			if isToUpper {
+				r += strings.ToUpper(string(v))
+				isToUpper = false
+			} else {
+				if v == '_' || v == '.' {
+					isToUpper = true
+				} else {
+					r += string(v)
+				}
+			}
+		}
+	}
+	r = strings.Replace(r, "Punctuation", "Punct", -1)
+	if r != "Constant" {
+		r = strings.Replace(r, "Constant", "Const", -1)
+	}
+	return "TS" + r
+}
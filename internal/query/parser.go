@@ -0,0 +1,374 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+type parser struct {
+	src  []byte
+	pos  Pos
+	q    *Query
+	seen bool
+}
+
+// Parse parses a tree-sitter query.
+func Parse(src []byte) (*Query, error) {
+	p := &parser{
+		src: src,
+		pos: Pos{Line: 1, Column: 1},
+		q:   &Query{},
+	}
+	for {
+		p.skip()
+		if p.eof() {
+			break
+		}
+		p.seen = true
+		n, err := p.parsePattern()
+		if err != nil {
+			return nil, err
+		}
+		switch n.Kind {
+		case Anchor, NegatedField:
+			return nil, p.errorf(n.Pos, "%q is only allowed inside a node", n.String())
+		}
+		if n.Field != "" {
+			return nil, p.errorf(n.Pos, "field %q is only allowed inside a node", n.Field)
+		}
+		p.q.Patterns = append(p.q.Patterns, n)
+	}
+	return p.q, nil
+}
+
+func (p *parser) eof() bool {
+	return p.pos.Offset >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos.Offset]
+}
+
+func (p *parser) next() byte {
+	c := p.src[p.pos.Offset]
+	p.pos.Offset++
+	if c == '\n' {
+		p.pos.Line++
+		p.pos.Column = 1
+	} else {
+		p.pos.Column++
+	}
+	return c
+}
+
+func (p *parser) errorf(pos Pos, format string, args ...interface{}) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) unexpected() error {
+	if p.eof() {
+		return p.errorf(p.pos, "unexpected end of query")
+	}
+	return p.errorf(p.pos, "unexpected %q", p.peek())
+}
+
+// skip skips white space and comments. Modelines in the comments before the
+// first pattern are recorded in the query.
+func (p *parser) skip() {
+	for !p.eof() {
+		c := p.peek()
+		if c == ';' {
+			start := p.pos.Offset
+			for !p.eof() && p.peek() != '\n' {
+				p.next()
+			}
+			if !p.seen {
+				p.modeline(string(p.src[start:p.pos.Offset]))
+			}
+		} else if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			p.next()
+		} else {
+			break
+		}
+	}
+}
+
+func (p *parser) modeline(line string) {
+	line = strings.TrimSpace(strings.TrimLeft(line, ";"))
+	if line == "extends" {
+		p.q.Extends = true
+		return
+	}
+	if !strings.HasPrefix(line, "inherits") {
+		return
+	}
+	line = strings.TrimPrefix(strings.TrimSpace(line[len("inherits"):]), ":")
+	for _, v := range strings.Split(line, ",") {
+		v = strings.Trim(strings.TrimSpace(v), "()")
+		if v != "" {
+			p.q.Inherits = append(p.q.Inherits, v)
+		}
+	}
+}
+
+func isIdent(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *parser) ident(extra string) string {
+	start := p.pos.Offset
+	for !p.eof() && (isIdent(p.peek()) || strings.IndexByte(extra, p.peek()) >= 0) {
+		p.next()
+	}
+	return string(p.src[start:p.pos.Offset])
+}
+
+func (p *parser) parseString() (string, error) {
+	start := p.pos
+	p.next()
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf(start, "unterminated string")
+		}
+		c := p.next()
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\n':
+			return "", p.errorf(start, "unterminated string")
+		case '\\':
+			if p.eof() {
+				return "", p.errorf(start, "unterminated string")
+			}
+			switch e := p.next(); e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '0':
+				b.WriteByte(0)
+			default:
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// parsePattern parses a single pattern together with its field name,
+// quantifier and captures.
+func (p *parser) parsePattern() (*Node, error) {
+	start := p.pos
+	var n *Node
+	var err error
+	switch c := p.peek(); {
+	case c == '(':
+		n, err = p.parseParen()
+	case c == '[':
+		n, err = p.parseAlternation()
+	case c == '"':
+		var s string
+		s, err = p.parseString()
+		n = &Node{Kind: Anonymous, Name: s}
+	case c == '.':
+		p.next()
+		return &Node{Kind: Anchor, Pos: start, End: p.pos}, nil
+	case c == '!':
+		p.next()
+		name := p.ident("")
+		if name == "" {
+			return nil, p.unexpected()
+		}
+		return &Node{Kind: NegatedField, Pos: start, End: p.pos, Name: name}, nil
+	case isIdent(c):
+		name := p.ident("")
+		if name == "_" {
+			n = &Node{Kind: Wildcard}
+			break
+		}
+		if p.peek() != ':' {
+			return nil, p.errorf(start, "unexpected identifier %q", name)
+		}
+		p.next()
+		p.skip()
+		if p.peek() == '.' || p.peek() == '!' {
+			return nil, p.unexpected()
+		}
+		n, err = p.parsePattern()
+		if err != nil {
+			return nil, err
+		}
+		if n.Field != "" {
+			return nil, p.errorf(n.Pos, "field %q already has field %q", name, n.Field)
+		}
+		n.Field = name
+		n.Pos = start
+		return n, nil
+	default:
+		return nil, p.unexpected()
+	}
+	if err != nil {
+		return nil, err
+	}
+	n.Pos = start
+	return n, p.parseSuffix(n)
+}
+
+func (p *parser) parseSuffix(n *Node) error {
+	for {
+		n.End = p.pos
+		save := p.pos
+		p.skip()
+		switch c := p.peek(); c {
+		case '*', '+', '?':
+			if n.Quantifier != 0 || len(n.Captures) > 0 {
+				return p.errorf(p.pos, "unexpected quantifier %q", c)
+			}
+			p.next()
+			n.Quantifier = c
+		case '@':
+			pos := p.pos
+			p.next()
+			name := p.ident(".")
+			if name == "" {
+				return p.errorf(pos, "missing capture name")
+			}
+			n.Captures = append(n.Captures, &Capture{Pos: pos, Name: name})
+		default:
+			p.pos = save
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseAlternation() (*Node, error) {
+	start := p.pos
+	p.next()
+	n := &Node{Kind: Alternation}
+	for {
+		p.skip()
+		if p.eof() {
+			return nil, p.errorf(start, "unclosed '['")
+		}
+		if p.peek() == ']' {
+			p.next()
+			break
+		}
+		c, err := p.parsePattern()
+		if err != nil {
+			return nil, err
+		}
+		if c.Kind == Anchor || c.Kind == NegatedField {
+			return nil, p.errorf(c.Pos, "%q is not allowed in an alternation", c.String())
+		}
+		n.Children = append(n.Children, c)
+	}
+	if len(n.Children) == 0 {
+		return nil, p.errorf(start, "empty alternation")
+	}
+	return n, nil
+}
+
+func (p *parser) parsePredicate(start Pos) (*Predicate, error) {
+	p.next()
+	p.next()
+	name := p.ident(".?!")
+	if name == "" {
+		return nil, p.unexpected()
+	}
+	pred := &Predicate{Pos: start, Name: name}
+	for {
+		p.skip()
+		pos := p.pos
+		switch c := p.peek(); {
+		case c == ')':
+			p.next()
+			return pred, nil
+		case c == '@':
+			p.next()
+			v := p.ident(".")
+			if v == "" {
+				return nil, p.errorf(pos, "missing capture name")
+			}
+			pred.Args = append(pred.Args, &Arg{Pos: pos, Capture: true, Value: v})
+		case c == '"':
+			v, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			pred.Args = append(pred.Args, &Arg{Pos: pos, Value: v})
+		case isIdent(c) || c == '.':
+			pred.Args = append(pred.Args, &Arg{Pos: pos, Value: p.ident(".?!")})
+		case p.eof():
+			return nil, p.errorf(start, "unclosed predicate")
+		default:
+			return nil, p.unexpected()
+		}
+	}
+}
+
+func (p *parser) parseParen() (*Node, error) {
+	start := p.pos
+	p.next()
+	p.skip()
+	n := &Node{Kind: Group}
+	if c := p.peek(); isIdent(c) {
+		n.Kind = Named
+		n.Name = p.ident("")
+		if p.peek() == ':' {
+			return nil, p.errorf(start, "field %q must be inside a node", n.Name)
+		}
+		if n.Name == "MISSING" {
+			p.skip()
+			switch c := p.peek(); {
+			case c == '"':
+				s, err := p.parseString()
+				if err != nil {
+					return nil, err
+				}
+				n.Children = append(n.Children, &Node{Kind: Anonymous, Name: s})
+			case isIdent(c):
+				n.Children = append(n.Children, &Node{Kind: Named, Name: p.ident("")})
+			}
+		}
+	} else if c == '#' {
+		return nil, p.errorf(start, "predicate is not allowed here")
+	}
+	for {
+		p.skip()
+		if p.eof() {
+			return nil, p.errorf(start, "unclosed '('")
+		}
+		if p.peek() == ')' {
+			p.next()
+			break
+		}
+		if p.peek() == '(' && p.pos.Offset+1 < len(p.src) && p.src[p.pos.Offset+1] == '#' {
+			pred, err := p.parsePredicate(p.pos)
+			if err != nil {
+				return nil, err
+			}
+			n.Predicates = append(n.Predicates, pred)
+			continue
+		}
+		c, err := p.parsePattern()
+		if err != nil {
+			return nil, err
+		}
+		if n.Kind == Group && (c.Kind == NegatedField || c.Field != "") {
+			return nil, p.errorf(c.Pos, "%q is only allowed inside a node", c.String())
+		}
+		n.Children = append(n.Children, c)
+	}
+	if n.Kind == Group && len(n.Children) == 0 {
+		return nil, p.errorf(start, "empty group")
+	}
+	return n, nil
+}
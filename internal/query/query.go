@@ -0,0 +1,195 @@
+// Package query reads tree-sitter query files (highlights.scm and friends)
+// into a syntax tree that keeps the source position of every node.
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Pos is a position in a query source. Line and Column are 1-based, Column
+// counts bytes.
+type Pos struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Kind is the kind of a query node.
+type Kind int
+
+const (
+	// Named is a parenthesized node pattern such as (identifier) or (_).
+	Named Kind = iota
+	// Anonymous is a string pattern such as "func".
+	Anonymous
+	// Wildcard is the bare _ pattern which matches any node.
+	Wildcard
+	// Alternation is a [...] pattern.
+	Alternation
+	// Group is a parenthesized sequence of sibling patterns.
+	Group
+	// Anchor is the . operator between or around child patterns.
+	Anchor
+	// NegatedField is a !field constraint inside a named node.
+	NegatedField
+)
+
+// Capture is an @name following a pattern. Name excludes the @.
+type Capture struct {
+	Pos  Pos
+	Name string
+}
+
+// Arg is an argument of a predicate.
+type Arg struct {
+	Pos     Pos
+	Capture bool
+	Value   string
+}
+
+func (a *Arg) String() string {
+	if a.Capture {
+		return "@" + a.Value
+	}
+	return strconv.Quote(a.Value)
+}
+
+// Predicate is a (#name? args...) or (#name! args...) form.
+type Predicate struct {
+	Pos  Pos
+	Name string
+	Args []*Arg
+}
+
+func (p *Predicate) String() string {
+	s := "(#" + p.Name
+	for _, a := range p.Args {
+		s += " " + a.String()
+	}
+	return s + ")"
+}
+
+// Node is a pattern in a query.
+type Node struct {
+	Kind Kind
+	Pos  Pos
+	End  Pos
+	// Name is the node type for Named nodes, the text for Anonymous nodes
+	// and the field name for NegatedField nodes.
+	Name string
+	// Field is the field name this pattern is bound to, if any.
+	Field      string
+	Children   []*Node
+	Captures   []*Capture
+	Quantifier byte
+	Predicates []*Predicate
+}
+
+// Walk calls fn for n and all patterns below it in source order. If fn
+// returns false, the children of that node are skipped.
+func (n *Node) Walk(fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// CaptureNames returns the names of all captures in the pattern.
+func (n *Node) CaptureNames() []string {
+	var names []string
+	n.Walk(func(c *Node) bool {
+		for _, cp := range c.Captures {
+			names = append(names, cp.Name)
+		}
+		return true
+	})
+	return names
+}
+
+// AllPredicates returns all predicates in the pattern.
+func (n *Node) AllPredicates() []*Predicate {
+	var preds []*Predicate
+	n.Walk(func(c *Node) bool {
+		preds = append(preds, c.Predicates...)
+		return true
+	})
+	return preds
+}
+
+// String returns the canonical form of the pattern.
+func (n *Node) String() string {
+	var b strings.Builder
+	if n.Field != "" {
+		b.WriteString(n.Field + ": ")
+	}
+	switch n.Kind {
+	case Named, Group:
+		b.WriteString("(")
+		var items []string
+		if n.Kind == Named {
+			items = append(items, n.Name)
+		}
+		for _, c := range n.Children {
+			if n.Name == "MISSING" && c.Kind == Named {
+				items = append(items, c.Name)
+				continue
+			}
+			items = append(items, c.String())
+		}
+		for _, p := range n.Predicates {
+			items = append(items, p.String())
+		}
+		b.WriteString(strings.Join(items, " "))
+		b.WriteString(")")
+	case Anonymous:
+		b.WriteString(strconv.Quote(n.Name))
+	case Wildcard:
+		b.WriteString("_")
+	case Alternation:
+		b.WriteString("[")
+		var items []string
+		for _, c := range n.Children {
+			items = append(items, c.String())
+		}
+		b.WriteString(strings.Join(items, " "))
+		b.WriteString("]")
+	case Anchor:
+		b.WriteString(".")
+	case NegatedField:
+		b.WriteString("!" + n.Name)
+	}
+	if n.Quantifier != 0 {
+		b.WriteByte(n.Quantifier)
+	}
+	for _, c := range n.Captures {
+		b.WriteString(" @" + c.Name)
+	}
+	return b.String()
+}
+
+// Query is a parsed query file.
+type Query struct {
+	// Inherits lists the languages named in a "; inherits:" modeline.
+	Inherits []string
+	// Extends is set when the file has an "; extends" modeline.
+	Extends  bool
+	Patterns []*Node
+}
+
+// Error is a syntax error in a query.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
@@ -0,0 +1,173 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePatterns(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []string
+	}{
+		// Fields and negated fields.
+		{`(call function: (identifier) @f)`, []string{`(call function: (identifier) @f)`}},
+		{`(call  function:(identifier)  !arguments)`, []string{`(call function: (identifier) !arguments)`}},
+		{`(pair key: _ value: "x")`, []string{`(pair key: _ value: "x")`}},
+		// Quantifiers.
+		{`(comment)+ @doc`, []string{`(comment)+ @doc`}},
+		{`(args (identifier)* @a (number)? @n)`, []string{`(args (identifier)* @a (number)? @n)`}},
+		{`((comment) @c (function))`, []string{`((comment) @c (function))`}},
+		// Anchors.
+		{`(block . (statement) @first)`, []string{`(block . (statement) @first)`}},
+		{`(block (statement) @last .)`, []string{`(block (statement) @last .)`}},
+		// Alternations.
+		{`["if" "else" (elif)] @keyword`, []string{`["if" "else" (elif)] @keyword`}},
+		{`(x [(a) (b)]+ @y)`, []string{`(x [(a) (b)]+ @y)`}},
+		// Predicates.
+		{`((identifier) @c (#match? @c "^[A-Z]"))`, []string{`((identifier) @c (#match? @c "^[A-Z]"))`}},
+		{`((x) @a (#set! priority 105) (#not-eq? @a "\"q\""))`, []string{`((x) @a (#set! "priority" "105") (#not-eq? @a "\"q\""))`}},
+		// Several captures, MISSING, strings with escapes and comments.
+		{"; a comment\n(a) @x @y ; trailing\n\n(MISSING \";\") @m\n\"\\t\\n\" @s",
+			[]string{`(a) @x @y`, `(MISSING ";") @m`, `"\t\n" @s`}},
+		{`(MISSING identifier) @m`, []string{`(MISSING identifier) @m`}},
+		{`(capture) @keyword.function.builtin`, []string{`(capture) @keyword.function.builtin`}},
+	} {
+		q, err := Parse([]byte(tt.src))
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		var got []string
+		for _, p := range q.Patterns {
+			got = append(got, p.String())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %q; want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"(identifier) @variable\n\n(call_expression\n", "3:1: unclosed '('"},
+		{"[(a) (b)", "1:1: unclosed '['"},
+		{"[]", "1:1: empty alternation"},
+		{"()", "1:1: empty group"},
+		{`"abc`, "1:1: unterminated string"},
+		{"\"ab\nc\"", "1:1: unterminated string"},
+		{"(a) )", "1:5: unexpected ')'"},
+		{"(a) @", "1:5: missing capture name"},
+		{"(a)* +", "1:6: unexpected quantifier '+'"},
+		{"(a) @x ?", "1:8: unexpected quantifier '?'"},
+		{"  foo", "1:3: unexpected identifier \"foo\""},
+		{"field: (a)", "1:1: field \"field\" is only allowed inside a node"},
+		{"(a f: g: (b))", "1:7: field \"f\" already has field \"g\""},
+		{"(a f: .)", "1:7: unexpected '.'"},
+		{"(field: (a))", "1:1: field \"field\" must be inside a node"},
+		{"((a) !f)", "1:6: \"!f\" is only allowed inside a node"},
+		{"((a) f: (b))", "1:6: \"f: (b)\" is only allowed inside a node"},
+		{"[(a) . (b)]", "1:6: \".\" is not allowed in an alternation"},
+		{".", "1:1: \".\" is only allowed inside a node"},
+		{"!f", "1:1: \"!f\" is only allowed inside a node"},
+		{"(#eq? @a \"b\")", "1:1: predicate is not allowed here"},
+		{"((a) @a (#eq? @a", "1:9: unclosed predicate"},
+		{"((a) @a (#eq? @ \"x\"))", "1:15: missing capture name"},
+		{"((a) (# ))", "1:8: unexpected ' '"},
+		{"(a) !", "1:6: unexpected end of query"},
+	} {
+		_, err := Parse([]byte(tt.src))
+		if err == nil {
+			t.Errorf("Parse(%q): no error, want %s", tt.src, tt.want)
+			continue
+		}
+		if _, ok := err.(*Error); !ok {
+			t.Errorf("Parse(%q): error %T is not an *Error", tt.src, err)
+		}
+		if err.Error() != tt.want {
+			t.Errorf("Parse(%q): %v; want %s", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	src := "; inherits: ecma,jsx\n\n(call\n  function: (identifier) @f\n  (#eq? @f \"x\"))\n\"if\" @keyword"
+	q, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.Patterns) != 2 {
+		t.Fatalf("got %d patterns", len(q.Patterns))
+	}
+	call := q.Patterns[0]
+	field := call.Children[0]
+	pred := call.Predicates[0]
+	for _, tt := range []struct {
+		what string
+		got  Pos
+		want string
+	}{
+		{"pattern", call.Pos, "3:1"},
+		{"pattern end", call.End, "5:17"},
+		{"field", field.Pos, "4:3"},
+		{"capture", field.Captures[0].Pos, "4:26"},
+		{"predicate", pred.Pos, "5:3"},
+		{"predicate capture", pred.Args[0].Pos, "5:9"},
+		{"predicate string", pred.Args[1].Pos, "5:12"},
+		{"second pattern", q.Patterns[1].Pos, "6:1"},
+	} {
+		if tt.got.String() != tt.want {
+			t.Errorf("%s at %v; want %s", tt.what, tt.got, tt.want)
+		}
+	}
+	if got := src[call.Pos.Offset:call.End.Offset]; got != "(call\n  function: (identifier) @f\n  (#eq? @f \"x\"))" {
+		t.Errorf("pattern source %q", got)
+	}
+	if !reflect.DeepEqual(call.CaptureNames(), []string{"f"}) {
+		t.Errorf("capture names %v", call.CaptureNames())
+	}
+	if len(call.AllPredicates()) != 1 || pred.Name != "eq?" || !pred.Args[0].Capture || pred.Args[1].Capture {
+		t.Errorf("predicates %v", call.AllPredicates())
+	}
+}
+
+func TestModelines(t *testing.T) {
+	for _, tt := range []struct {
+		src      string
+		inherits []string
+		extends  bool
+	}{
+		{"; inherits: ecma,jsx\n(a)", []string{"ecma", "jsx"}, false},
+		{";; inherits: (html_tags)\n; extends\n(a)", []string{"html_tags"}, true},
+		{"(a)\n; extends\n; inherits: c", nil, false},
+		{"; just a comment\n(a)", nil, false},
+	} {
+		q, err := Parse([]byte(tt.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(q.Inherits, tt.inherits) || q.Extends != tt.extends {
+			t.Errorf("Parse(%q): inherits %v, extends %v", tt.src, q.Inherits, q.Extends)
+		}
+	}
+}
+
+func TestHighlightGroup(t *testing.T) {
+	for capture, want := range map[string]string{
+		"keyword":             "TSKeyword",
+		"keyword.function":    "TSKeywordFunction",
+		"punctuation.bracket": "TSPunctBracket",
+		"constant":            "TSConstant",
+		"constant.builtin":    "TSConstBuiltin",
+		"function.macro":      "TSFunctionMacro",
+		"text.title":          "TSTextTitle",
+		"string_special":      "TSStringSpecial",
+		"parameter.reference": "TSParameterReference",
+	} {
+		if got := HighlightGroup(capture); got != want {
+			t.Errorf("HighlightGroup(%q) = %s; want %s", capture, got, want)
+		}
+	}
+}
@@ -53,6 +53,8 @@ highlight default link TSFloat Float
 highlight default link TSFunction Function
 highlight default link TSFuncBuiltin Special
 highlight default link TSFuncMacro Macro
+highlight default link TSFunctionBuiltin TSFuncBuiltin
+highlight default link TSFunctionMacro TSFuncMacro
 highlight default link TSParameter Identifier
 highlight default link TSParameterReference TSParameter
 highlight default link TSMethod Function
@@ -78,6 +80,7 @@ highlight default link TSType Type
 highlight default link TSTypeBuiltin Type
 highlight default link TSInclude Include
 
+highlight default link TSVariable TSNone
 highlight default link TSVariableBuiltin Special
 
 highlight default link TSText TSNone
@@ -97,6 +100,7 @@ highlight default link TSComment Comment
 highlight default link TSNote SpecialComment
 highlight default link TSWarning Todo
 highlight default link TSDanger WarningMsg
+highlight default link TSError TSNone
 
 highlight default link TSTag Label
 highlight default link TSTagDelimiter Delimiter